@@ -0,0 +1,358 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultAuthRealm is the realm sent in WWW-Authenticate challenges if the
+// match rule does not specify one.
+const DefaultAuthRealm = "webhook"
+
+// Credential is a user name or label with a password or token hash.
+type Credential struct {
+	Name string
+	Hash string
+}
+
+// credentialFiles caches the credentials parsed from htpasswd-style files.
+var credentialFiles = newFileCache(func(data []byte) (interface{}, error) {
+	return ParseCredentials(data)
+})
+
+// ParseCredentials parses htpasswd-style credentials: one "name:hash" entry
+// per line.  The name may be omitted for bearer tokens.  Empty lines and lines
+// starting with "#" are ignored.
+func ParseCredentials(data []byte) ([]Credential, error) {
+	var creds []Credential
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		c, err := parseCredential(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		creds = append(creds, c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// parseCredential parses a single "name:hash" or "hash" entry.
+func parseCredential(s string) (Credential, error) {
+	var c Credential
+
+	if i := strings.IndexByte(s, ':'); i != -1 {
+		c.Name, c.Hash = s[:i], s[i+1:]
+	} else {
+		c.Hash = s
+	}
+
+	if c.Hash == "" {
+		return c, errors.New("empty hash")
+	}
+
+	if err := checkHashFormat(c.Hash); err != nil {
+		return c, err
+	}
+
+	return c, nil
+}
+
+// checkHashFormat checks that hash is a well-formed hash in one of the
+// formats supported by CheckPasswordHash.
+func checkHashFormat(hash string) error {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return fmt.Errorf("invalid bcrypt hash: %w", err)
+		}
+
+		return nil
+
+	case strings.HasPrefix(hash, "$argon2id$"), strings.HasPrefix(hash, "$argon2i$"):
+		_, err := parseArgon2Hash(hash)
+		return err
+
+	case strings.HasPrefix(hash, "{SHA256}"):
+		_, err := parseSHA256Hash(hash)
+		return err
+	}
+
+	return errors.New("unsupported hash format: must be bcrypt, argon2 or {SHA256}")
+}
+
+// CheckPasswordHash reports whether password matches the given hash.  The
+// supported formats are bcrypt ("$2a$", "$2b$" or "$2y$"), argon2 in the PHC
+// string format ("$argon2id$" or "$argon2i$") and base64 encoded SHA-256
+// ("{SHA256}").
+func CheckPasswordHash(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+
+		return err == nil, err
+
+	case strings.HasPrefix(hash, "$argon2id$"), strings.HasPrefix(hash, "$argon2i$"):
+		return checkArgon2Hash(hash, password)
+
+	case strings.HasPrefix(hash, "{SHA256}"):
+		expected, err := parseSHA256Hash(hash)
+		if err != nil {
+			return false, err
+		}
+
+		sum := sha256.Sum256([]byte(password))
+
+		return subtle.ConstantTimeCompare(sum[:], expected) == 1, nil
+	}
+
+	return false, errors.New("unsupported hash format: must be bcrypt, argon2 or {SHA256}")
+}
+
+// parseSHA256Hash decodes a base64 encoded "{SHA256}" hash.
+func parseSHA256Hash(hash string) ([]byte, error) {
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(hash, "{SHA256}"))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, errors.New("invalid SHA-256 hash")
+	}
+
+	return sum, nil
+}
+
+// argon2Hash is a parsed argon2 hash.
+type argon2Hash struct {
+	id                    bool
+	memory, time, threads uint64
+	salt, key             []byte
+}
+
+// parseArgon2Hash parses an argon2 hash in the PHC string format, such as
+// "$argon2id$v=19$m=65536,t=3,p=4$salt$key".
+func parseArgon2Hash(hash string) (*argon2Hash, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[2] != "v=19" {
+		return nil, errors.New("invalid argon2 hash")
+	}
+
+	h := &argon2Hash{id: parts[1] == "argon2id"}
+
+	for _, param := range strings.Split(parts[3], ",") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("invalid argon2 hash parameters")
+		}
+
+		v, err := strconv.ParseUint(kv[1], 10, 32)
+		if err != nil {
+			return nil, errors.New("invalid argon2 hash parameters")
+		}
+
+		switch kv[0] {
+		case "m":
+			h.memory = v
+		case "t":
+			h.time = v
+		case "p":
+			h.threads = v
+		}
+	}
+
+	if h.memory == 0 || h.time == 0 || h.threads == 0 || h.threads > 255 {
+		return nil, errors.New("invalid argon2 hash parameters")
+	}
+
+	var err error
+
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, errors.New("invalid argon2 salt")
+	}
+
+	if h.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(h.key) == 0 {
+		return nil, errors.New("invalid argon2 key")
+	}
+
+	return h, nil
+}
+
+// checkArgon2Hash verifies a password against an argon2 hash in the PHC
+// string format.
+func checkArgon2Hash(hash, password string) (bool, error) {
+	h, err := parseArgon2Hash(hash)
+	if err != nil {
+		return false, err
+	}
+
+	var actual []byte
+
+	if h.id {
+		actual = argon2.IDKey([]byte(password), h.salt, uint32(h.time), uint32(h.memory), uint8(h.threads), uint32(len(h.key)))
+	} else {
+		actual = argon2.Key([]byte(password), h.salt, uint32(h.time), uint32(h.memory), uint8(h.threads), uint32(len(h.key)))
+	}
+
+	return subtle.ConstantTimeCompare(actual, h.key) == 1, nil
+}
+
+// credentials returns the inline and file credentials of the match rule.
+func (r MatchRule) credentials() ([]Credential, error) {
+	var creds []Credential
+
+	for _, s := range r.Credentials {
+		c, err := parseCredential(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid credential: %w", err)
+		}
+
+		creds = append(creds, c)
+	}
+
+	if r.CredentialsFile != "" {
+		v, err := credentialFiles.Get(r.CredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading credentials file: %w", err)
+		}
+
+		creds = append(creds, v.([]Credential)...)
+	}
+
+	if len(creds) == 0 {
+		return nil, errors.New("no credentials defined")
+	}
+
+	return creds, nil
+}
+
+// authorization returns the value of the rule's parameter, which defaults to
+// the Authorization header.  A missing value is returned as the empty string.
+func (r MatchRule) authorization(req *Request) (string, error) {
+	arg := r.Parameter
+	if arg.Source == "" {
+		arg = Argument{Source: SourceHeader, Name: "Authorization"}
+	}
+
+	v, err := arg.Get(req)
+	if IsParameterNodeError(err) {
+		return "", nil
+	}
+
+	if err == nil {
+		req.traceValue(r, v)
+	}
+
+	return v, err
+}
+
+// challenge records the WWW-Authenticate challenge for a failed
+// authentication in the request.  The composite rules keep the challenge
+// only while the failed authentication decides their outcome.
+func (r MatchRule) challenge(req *Request, scheme string) {
+	realm := r.Realm
+	if realm == "" {
+		realm = DefaultAuthRealm
+	}
+
+	req.AuthChallenge = fmt.Sprintf("%s realm=%q", scheme, realm)
+}
+
+// checkBasicAuth evaluates the basic-auth match rule.
+func (r MatchRule) checkBasicAuth(req *Request) (bool, error) {
+	creds, err := r.credentials()
+	if err != nil {
+		return false, err
+	}
+
+	auth, err := r.authorization(req)
+	if err != nil {
+		return false, err
+	}
+
+	if len(auth) > 6 && strings.EqualFold(auth[:6], "basic ") {
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(auth[6:])); err == nil {
+			if user, password, ok := strings.Cut(string(decoded), ":"); ok {
+				for _, c := range creds {
+					if c.Name != user {
+						continue
+					}
+
+					ok, err := CheckPasswordHash(c.Hash, password)
+					if err != nil {
+						return false, err
+					}
+
+					if ok {
+						return true, nil
+					}
+				}
+			}
+		}
+	}
+
+	r.challenge(req, "Basic")
+
+	return false, nil
+}
+
+// checkBearerToken evaluates the bearer-token match rule.
+func (r MatchRule) checkBearerToken(req *Request) (bool, error) {
+	creds, err := r.credentials()
+	if err != nil {
+		return false, err
+	}
+
+	auth, err := r.authorization(req)
+	if err != nil {
+		return false, err
+	}
+
+	token := auth
+
+	// The Authorization header must use the Bearer scheme, while other
+	// parameters may contain the bare token.
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		token = strings.TrimSpace(auth[7:])
+	} else if r.Parameter.Source == "" {
+		token = ""
+	}
+
+	if token != "" {
+		for _, c := range creds {
+			ok, err := CheckPasswordHash(c.Hash, token)
+			if err != nil {
+				return false, err
+			}
+
+			if ok {
+				return true, nil
+			}
+		}
+	}
+
+	r.challenge(req, "Bearer")
+
+	return false, nil
+}
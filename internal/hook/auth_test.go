@@ -0,0 +1,181 @@
+package hook
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPasswordHash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		hash, password string
+		match, ok      bool
+	}{
+		{string(bcryptHash), "password", true, true},
+		// Example from the argon2 reference implementation's README.
+		{"$argon2i$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG", "password", true, true},
+		{"$argon2id$v=19$m=16,t=2,p=1$c29tZXNhbHQ$3Dmj9qAPHVyegJFvp35EiA", "password", false, true},
+		{"{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=", "password", true, true},
+		// mismatches
+		{string(bcryptHash), "Password", false, true},
+		{"$argon2i$v=19$m=65536,t=2,p=4$c29tZXNhbHQ$RdescudvJCsgt3ub+b+dWRWJTmaaJObG", "passwore", false, true},
+		{"{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=", "", false, true},
+		// errors
+		{"password", "password", false, false},
+		{"$2a$10$short", "password", false, false},
+		{"$argon2id$v=19$m=0,t=2,p=1$c29tZXNhbHQ$3Dmj9qAPHVyegJFvp35EiA", "password", false, false},
+		{"{SHA256}cGFzc3dvcmQ=", "password", false, false},
+	} {
+		match, err := CheckPasswordHash(tt.hash, tt.password)
+		if match != tt.match || (err == nil) != tt.ok {
+			t.Errorf("failed to check %q against %q:\nexpected {match:%v, ok:%v}\ngot {match:%v, err:%v}", tt.password, tt.hash, tt.match, tt.ok, match, err)
+		}
+	}
+}
+
+func TestParseCredentials(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("token"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := ParseCredentials([]byte("# deployers\nalice:{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=\n\n  " + string(bcryptHash) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Credential{
+		{Name: "alice", Hash: "{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg="},
+		{Hash: string(bcryptHash)},
+	}
+
+	if len(creds) != len(expected) || creds[0] != expected[0] || creds[1] != expected[1] {
+		t.Errorf("failed to parse credentials:\nexpected %#v\ngot %#v", expected, creds)
+	}
+
+	if _, err := ParseCredentials([]byte("alice:\n")); err == nil {
+		t.Error("expected error for empty hash")
+	}
+
+	for _, hash := range []string{"password", "$2a$10$short", "$argon2id$v=19$m=0,t=2,p=1$c29tZXNhbHQ$3Dmj9qAPHVyegJFvp35EiA", "{SHA256}cGFzc3dvcmQ="} {
+		if _, err := ParseCredentials([]byte("alice:" + hash + "\n")); err == nil {
+			t.Errorf("expected error for invalid hash %q", hash)
+		}
+	}
+}
+
+func TestAuthMatchRules(t *testing.T) {
+	// {SHA256} hashes of "password" and "token".
+	passwordHash := "{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg="
+	tokenHash := "{SHA256}PEaenWxYddN6Q/NT1PiOYfz4EsZu7jRXRlpAsNpBU+A="
+
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(file, []byte("bob:"+passwordHash+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+
+	for _, tt := range []struct {
+		desc      string
+		rule      MatchRule
+		headers   map[string]interface{}
+		query     map[string]interface{}
+		ok        bool
+		challenge string
+		err       bool
+	}{
+		{"basic inline", MatchRule{Type: "basic-auth", Credentials: []string{"alice:" + passwordHash}}, map[string]interface{}{"Authorization": basic("alice", "password")}, nil, true, "", false},
+		{"basic file", MatchRule{Type: "basic-auth", CredentialsFile: file}, map[string]interface{}{"Authorization": basic("bob", "password")}, nil, true, "", false},
+		{"bearer", MatchRule{Type: "bearer-token", Credentials: []string{tokenHash}}, map[string]interface{}{"Authorization": "Bearer token"}, nil, true, "", false},
+		{"bearer query parameter", MatchRule{Type: "bearer-token", Credentials: []string{"ci:" + tokenHash}, Parameter: Argument{Source: "url", Name: "token"}}, nil, map[string]interface{}{"token": "token"}, true, "", false},
+		// failures
+		{"basic wrong password", MatchRule{Type: "basic-auth", Credentials: []string{"alice:" + passwordHash}}, map[string]interface{}{"Authorization": basic("alice", "wrong")}, nil, false, `Basic realm="webhook"`, false},
+		{"basic wrong user", MatchRule{Type: "basic-auth", Credentials: []string{"alice:" + passwordHash}, Realm: "deploy"}, map[string]interface{}{"Authorization": basic("bob", "password")}, nil, false, `Basic realm="deploy"`, false},
+		{"basic missing header", MatchRule{Type: "basic-auth", Credentials: []string{"alice:" + passwordHash}}, nil, nil, false, `Basic realm="webhook"`, false},
+		{"bearer wrong scheme", MatchRule{Type: "bearer-token", Credentials: []string{tokenHash}}, map[string]interface{}{"Authorization": "token"}, nil, false, `Bearer realm="webhook"`, false},
+		// errors
+		{"no credentials", MatchRule{Type: "basic-auth"}, map[string]interface{}{"Authorization": basic("alice", "password")}, nil, false, "", true},
+		{"missing file", MatchRule{Type: "basic-auth", CredentialsFile: file + ".missing"}, map[string]interface{}{"Authorization": basic("bob", "password")}, nil, false, "", true},
+		{"plaintext credential", MatchRule{Type: "bearer-token", Credentials: []string{"token"}}, map[string]interface{}{"Authorization": "Bearer token"}, nil, false, "", true},
+	} {
+		req := &Request{Headers: tt.headers, Query: tt.query}
+
+		ok, err := tt.rule.Evaluate(req)
+		if ok != tt.ok || (err != nil) != tt.err || req.AuthChallenge != tt.challenge {
+			t.Errorf("%s failed:\nexpected {ok:%v, challenge:%q, err:%v}\ngot {ok:%v, challenge:%q, err:%v}", tt.desc, tt.ok, tt.challenge, tt.err, ok, req.AuthChallenge, err)
+		}
+	}
+}
+
+func TestAuthChallengeCompositeRules(t *testing.T) {
+	// {SHA256} hash of "password".
+	passwordHash := "{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg="
+
+	basic := Rules{Match: &MatchRule{Type: MatchBasicAuth, Credentials: []string{"alice:" + passwordHash}}}
+	bearer := Rules{Match: &MatchRule{Type: MatchBearerToken, Credentials: []string{passwordHash}}}
+	pass := Rules{Match: &MatchRule{Type: MatchValue, Value: "main", Parameter: Argument{Source: SourcePayload, Name: "ref"}}}
+	fail := Rules{Match: &MatchRule{Type: MatchValue, Value: "dev", Parameter: Argument{Source: SourcePayload, Name: "ref"}}}
+
+	for _, tt := range []struct {
+		desc      string
+		rule      Rules
+		ok        bool
+		challenge string
+	}{
+		{"failed authentication", basic, false, `Basic realm="webhook"`},
+		{"and with failed authentication", Rules{And: &AndRule{pass, basic}}, false, `Basic realm="webhook"`},
+		{"and failing after authentication", Rules{And: &AndRule{basic, fail}}, false, `Basic realm="webhook"`},
+		{"or with other rule succeeding", Rules{Or: &OrRule{basic, pass}}, true, ""},
+		{"or with all rules failing", Rules{Or: &OrRule{fail, bearer, basic}}, false, `Bearer realm="webhook"`},
+		{"not", Rules{Not: (*NotRule)(&basic)}, true, ""},
+		{"not in and", Rules{And: &AndRule{Rules{Not: (*NotRule)(&basic)}, fail}}, false, ""},
+		{"losing or branch", Rules{And: &AndRule{Rules{Or: &OrRule{basic, pass}}, fail}}, false, ""},
+	} {
+		req := &Request{Payload: map[string]interface{}{"ref": "main"}}
+
+		ok, err := tt.rule.Evaluate(req)
+		if err != nil || ok != tt.ok || req.AuthChallenge != tt.challenge {
+			t.Errorf("%s failed:\nexpected {ok:%v, challenge:%q}\ngot {ok:%v, challenge:%q, err:%v}", tt.desc, tt.ok, tt.challenge, ok, req.AuthChallenge, err)
+		}
+	}
+}
+
+func TestCredentialsFileReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+
+	write := func(hash string, mtime time.Time) {
+		if err := os.WriteFile(file, []byte("alice:"+hash+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := MatchRule{Type: "basic-auth", CredentialsFile: file}
+	req := &Request{Headers: map[string]interface{}{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:password"))}}
+
+	write("{SHA256}PEaenWxYddN6Q/NT1PiOYfz4EsZu7jRXRlpAsNpBU+A=", time.Now().Add(-time.Hour))
+
+	if ok, _ := r.Evaluate(req); ok {
+		t.Fatal("password should not match the initial credentials")
+	}
+
+	write("{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg=", time.Now())
+
+	if ok, err := r.Evaluate(req); !ok || err != nil {
+		t.Errorf("password should match after the credentials file changed: ok: %v, err: %v", ok, err)
+	}
+}
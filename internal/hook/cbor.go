@@ -0,0 +1,102 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// maxBignumSize is the maximum size in bytes of CBOR bignums.  Converting
+// bignums to decimal takes more than linear time, so larger ones are
+// rejected.
+const maxBignumSize = 16
+
+var cborDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		MaxNestedLevels:       maxDecodeDepth,
+		DefaultByteStringType: reflect.TypeOf(""),
+		UnrecognizedTagToAny:  cbor.UnrecognizedTagContentToAny,
+		TimeTagToAny:          cbor.TimeTagToRFC3339Nano,
+		NaN:                   cbor.NaNDecodeForbidden,
+		Inf:                   cbor.InfDecodeForbidden,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+
+	return dm
+}()
+
+// decodeCBOR decodes a CBOR value into the types produced by encoding/json
+// with UseNumber: maps with string keys, slices, strings, json.Number, bool
+// and nil.  Byte strings are decoded as strings, bignums as numbers, time
+// tags as RFC 3339 strings, and other tags are ignored.
+func decodeCBOR(data []byte) (interface{}, error) {
+	var v interface{}
+
+	if err := cborDecMode.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+
+	return cborValue(v)
+}
+
+// cborValue converts a value decoded by the CBOR decoder.
+func cborValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+
+		for k, e := range v {
+			k, err := cborValue(k)
+			if err != nil {
+				return nil, err
+			}
+
+			key, err := mapKey(k)
+			if err != nil {
+				return nil, err
+			}
+
+			if m[key], err = cborValue(e); err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+
+	case []interface{}:
+		a := make([]interface{}, len(v))
+
+		for i := range v {
+			var err error
+
+			if a[i], err = cborValue(v[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		return a, nil
+
+	case big.Int:
+		// The magnitude of negative bignums is -1-n, its bitwise complement.
+		n := &v
+		if n.Sign() < 0 {
+			n = new(big.Int).Not(n)
+		}
+
+		if n.BitLen() > 8*maxBignumSize {
+			return nil, fmt.Errorf("bignum exceeds %d bytes", maxBignumSize)
+		}
+
+		return json.Number(v.String()), nil
+
+	case cbor.SimpleValue:
+		return nil, fmt.Errorf("unsupported simple value %d", v)
+	}
+
+	return binaryScalar(v)
+}
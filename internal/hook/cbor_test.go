@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The test vectors are taken from RFC 8949, Appendix A.
+func TestDecodeCBOR(t *testing.T) {
+	for _, tt := range []struct {
+		data  string
+		value interface{}
+	}{
+		{"00", json.Number("0")},
+		{"17", json.Number("23")},
+		{"1818", json.Number("24")},
+		{"1bffffffffffffffff", json.Number("18446744073709551615")},
+		{"c249010000000000000000", json.Number("18446744073709551616")},
+		{"3bffffffffffffffff", json.Number("-18446744073709551616")},
+		{"c349010000000000000000", json.Number("-18446744073709551617")},
+		{"20", json.Number("-1")},
+		{"3863", json.Number("-100")},
+		{"f93c00", json.Number("1")},
+		{"f9c400", json.Number("-4")},
+		{"f97bff", json.Number("65504")},
+		{"f90001", json.Number("5.960464477539063e-08")},
+		{"fa47c35000", json.Number("100000")},
+		{"fb3ff199999999999a", json.Number("1.1")},
+		{"f4", false},
+		{"f5", true},
+		{"f6", nil},
+		{"f7", nil},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+		{"c11a514b67b0", "2013-03-21T20:04:00Z"},
+		{"c250ffffffffffffffffffffffffffffffff", json.Number("340282366920938463463374607431768211455")},
+		{"c350ffffffffffffffffffffffffffffffff", json.Number("-340282366920938463463374607431768211456")},
+		{"4401020304", "\x01\x02\x03\x04"},
+		{"6449455446", "IETF"},
+		{"80", []interface{}{}},
+		{"83010203", []interface{}{json.Number("1"), json.Number("2"), json.Number("3")}},
+		{"a201020304", map[string]interface{}{"1": json.Number("2"), "3": json.Number("4")}},
+		{"a26161016162820203", map[string]interface{}{"a": json.Number("1"), "b": []interface{}{json.Number("2"), json.Number("3")}}},
+		{"5f42010243030405ff", "\x01\x02\x03\x04\x05"},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9fff", []interface{}{}},
+		{"9f018202039f0405ffff", []interface{}{json.Number("1"), []interface{}{json.Number("2"), json.Number("3")}, []interface{}{json.Number("4"), json.Number("5")}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": json.Number("1"), "b": []interface{}{json.Number("2"), json.Number("3")}}},
+	} {
+		data, _ := hex.DecodeString(tt.data)
+
+		v, err := decodeCBOR(data)
+		if err != nil || !reflect.DeepEqual(v, tt.value) {
+			t.Errorf("failed to decode %s:\nexpected %#v\ngot %#v, err: %v", tt.data, tt.value, v, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		data, err string
+	}{
+		{"", "EOF"},
+		{"1c", "invalid additional information 28"},
+		{"1f", "invalid additional information 31"},
+		{"ff", `unexpected "break" code`},
+		{"6261", "unexpected EOF"},
+		{"9f01", "unexpected EOF"},
+		{"5f6161ff", "wrong element type"},
+		{"0000", "extraneous data"},
+		{"9bffffffffffffffff", "too large"},
+		{"a1f6f6", "unsupported map key type <nil>"},
+		{"f97c00", "floating-point infinity"},
+		{"f0", "unsupported simple value 16"},
+		{"c26161", "must be followed by byte string"},
+		{"c25101" + strings.Repeat("00", 16), "bignum exceeds 16 bytes"},
+		{"c351" + strings.Repeat("ff", 17), "bignum exceeds 16 bytes"},
+		{strings.Repeat("81", 1001) + "f6", "exceeded max nested level"},
+	} {
+		data, _ := hex.DecodeString(tt.data)
+
+		if _, err := decodeCBOR(data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("failed to reject %s:\nexpected error containing %q\ngot %v", tt.data, tt.err, err)
+		}
+	}
+}
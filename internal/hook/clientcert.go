@@ -0,0 +1,175 @@
+package hook
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ClientCertOptions describes the TLS client certificates accepted by the
+// client-cert match rule.  Each list matches if it contains any value of the
+// certificate; empty lists match any certificate.
+type ClientCertOptions struct {
+	// CommonNames lists the accepted subject common names.
+	CommonNames []string `json:"common-names,omitempty"`
+
+	// SANs lists the accepted subject alternative names: DNS names, email
+	// addresses, IP addresses or URIs.
+	SANs []string `json:"sans,omitempty"`
+
+	// Issuers lists the accepted issuer distinguished names or common
+	// names.
+	Issuers []string `json:"issuers,omitempty"`
+
+	// Fingerprints lists the accepted SHA-256 fingerprints of the
+	// certificate, in hex with optional colons.  If given, the certificate
+	// does not need to be verified against the client CAs.
+	Fingerprints []string `json:"fingerprints,omitempty"`
+}
+
+// peerCertificate returns the client certificate of the request, and whether
+// it was verified against the client CAs.
+func peerCertificate(r *http.Request) (*x509.Certificate, bool) {
+	if r == nil || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil, false
+	}
+
+	return r.TLS.PeerCertificates[0], len(r.TLS.VerifiedChains) != 0
+}
+
+// certificateFingerprint returns the SHA-256 fingerprint of the certificate
+// in lowercase hex.
+func certificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// certificateSANs returns the subject alternative names of the certificate.
+func certificateSANs(cert *x509.Certificate) []string {
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+
+	return sans
+}
+
+// ClientCertValues returns the values of the request's client certificate
+// available to the client-cert argument source, or nil if there is none.
+// The names of a certificate that was not verified against the client CAs
+// can not be trusted, so only its fingerprint is returned.
+func ClientCertValues(r *http.Request) map[string]interface{} {
+	cert, verified := peerCertificate(r)
+	if cert == nil {
+		return nil
+	}
+
+	if !verified {
+		return map[string]interface{}{
+			"fingerprint": certificateFingerprint(cert),
+			"verified":    false,
+		}
+	}
+
+	toList := func(s []string) []interface{} {
+		l := make([]interface{}, len(s))
+		for i := range s {
+			l[i] = s[i]
+		}
+		return l
+	}
+
+	ips := make([]string, len(cert.IPAddresses))
+	for i, ip := range cert.IPAddresses {
+		ips[i] = ip.String()
+	}
+
+	uris := make([]string, len(cert.URIs))
+	for i, uri := range cert.URIs {
+		uris[i] = uri.String()
+	}
+
+	return map[string]interface{}{
+		"cn":          cert.Subject.CommonName,
+		"subject":     cert.Subject.String(),
+		"issuer":      cert.Issuer.String(),
+		"issuer-cn":   cert.Issuer.CommonName,
+		"serial":      cert.SerialNumber.String(),
+		"fingerprint": certificateFingerprint(cert),
+		"san":         toList(certificateSANs(cert)),
+		"dns":         toList(cert.DNSNames),
+		"email":       toList(cert.EmailAddresses),
+		"ip":          toList(ips),
+		"uri":         toList(uris),
+		"verified":    true,
+	}
+}
+
+// CheckClientCert reports whether the request's client certificate matches
+// the given options.  Unless the options pin fingerprints, the certificate
+// must have been verified against the client CAs.
+func CheckClientCert(r *http.Request, opts *ClientCertOptions) (bool, error) {
+	if opts == nil {
+		return false, errors.New("client-cert match rule requires client-cert options")
+	}
+
+	cert, verified := peerCertificate(r)
+	if cert == nil {
+		return false, nil
+	}
+
+	if len(opts.Fingerprints) != 0 {
+		fingerprint := certificateFingerprint(cert)
+
+		var found bool
+
+		for _, f := range opts.Fingerprints {
+			if strings.ToLower(strings.ReplaceAll(f, ":", "")) == fingerprint {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	} else if !verified {
+		return false, nil
+	}
+
+	if len(opts.CommonNames) != 0 && !containsString(opts.CommonNames, cert.Subject.CommonName) {
+		return false, nil
+	}
+
+	if len(opts.Issuers) != 0 && !containsString(opts.Issuers, cert.Issuer.String()) && !containsString(opts.Issuers, cert.Issuer.CommonName) {
+		return false, nil
+	}
+
+	if len(opts.SANs) != 0 {
+		var found bool
+
+		for _, san := range certificateSANs(cert) {
+			if containsString(opts.SANs, san) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
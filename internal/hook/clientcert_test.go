@@ -0,0 +1,185 @@
+package hook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestClientCert creates a client certificate issued by a test CA.
+func newTestClientCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Internal CA", Organization: []string{"Example"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	spiffe, _ := url.Parse("spiffe://example.com/deployer")
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "deployer"},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+		DNSNames:       []string{"deployer.internal"},
+		EmailAddresses: []string{"ops@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{spiffe},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestClientCertArgument(t *testing.T) {
+	cert := newTestClientCert(t)
+	sum := sha256.Sum256(cert.Raw)
+
+	r := httptest.NewRequest("POST", "https://example.com/hooks/deploy", nil)
+	r.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+
+	req := &Request{RawRequest: r}
+
+	for _, tt := range []struct {
+		name, value string
+	}{
+		{"cn", "deployer"},
+		{"issuer", "CN=Internal CA,O=Example"},
+		{"issuer-cn", "Internal CA"},
+		{"serial", "42"},
+		{"fingerprint", hex.EncodeToString(sum[:])},
+		{"san", `["deployer.internal","ops@example.com","10.0.0.1","spiffe://example.com/deployer"]`},
+		{"dns.0", "deployer.internal"},
+		{"uri.0", "spiffe://example.com/deployer"},
+		{"verified", "true"},
+	} {
+		a := Argument{Source: "client-cert", Name: tt.name}
+
+		value, err := a.Get(req)
+		if err != nil || value != tt.value {
+			t.Errorf("failed to get client-cert %q:\nexpected %q\ngot %q, err: %v", tt.name, tt.value, value, err)
+		}
+	}
+
+	if _, err := (&Argument{Source: "client-cert", Name: "cn"}).Get(&Request{RawRequest: httptest.NewRequest("POST", "/", nil)}); err == nil {
+		t.Error("expected error without a client certificate")
+	}
+
+	// Only the fingerprint of an unverified certificate is available, since
+	// anyone can present a self-signed certificate with any name.
+	r.TLS.VerifiedChains = nil
+
+	for _, tt := range []struct {
+		name, value string
+		ok          bool
+	}{
+		{"fingerprint", hex.EncodeToString(sum[:]), true},
+		{"verified", "false", true},
+		{"cn", "", false},
+		{"san", "", false},
+		{"issuer", "", false},
+	} {
+		a := Argument{Source: "client-cert", Name: tt.name}
+
+		value, err := a.Get(req)
+		if (err == nil) != tt.ok || value != tt.value {
+			t.Errorf("unverified client-cert %q:\nexpected {value:%q, ok:%v}\ngot {value:%q, err:%v}", tt.name, tt.value, tt.ok, value, err)
+		}
+	}
+}
+
+func TestClientCertMatchRule(t *testing.T) {
+	cert := newTestClientCert(t)
+	sum := sha256.Sum256(cert.Raw)
+
+	fingerprint := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var colons []string
+	for i := 0; i < len(fingerprint); i += 2 {
+		colons = append(colons, fingerprint[i:i+2])
+	}
+
+	verified := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+	unverified := &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+	}
+
+	for _, tt := range []struct {
+		desc  string
+		state *tls.ConnectionState
+		opts  *ClientCertOptions
+		ok    bool
+		err   bool
+	}{
+		{"any verified", verified, &ClientCertOptions{}, true, false},
+		{"common name", verified, &ClientCertOptions{CommonNames: []string{"builder", "deployer"}}, true, false},
+		{"san", verified, &ClientCertOptions{SANs: []string{"spiffe://example.com/deployer"}}, true, false},
+		{"issuer", verified, &ClientCertOptions{Issuers: []string{"Internal CA"}, CommonNames: []string{"deployer"}}, true, false},
+		{"pinned fingerprint", unverified, &ClientCertOptions{Fingerprints: []string{strings.Join(colons, ":")}}, true, false},
+		// failures
+		{"no certificate", nil, &ClientCertOptions{}, false, false},
+		{"unverified", unverified, &ClientCertOptions{CommonNames: []string{"deployer"}}, false, false},
+		{"wrong common name", verified, &ClientCertOptions{CommonNames: []string{"builder"}}, false, false},
+		{"wrong san", verified, &ClientCertOptions{SANs: []string{"builder.internal"}}, false, false},
+		{"wrong issuer", verified, &ClientCertOptions{Issuers: []string{"Other CA"}}, false, false},
+		{"wrong fingerprint", verified, &ClientCertOptions{Fingerprints: []string{"00"}}, false, false},
+		// errors
+		{"no options", verified, nil, false, true},
+	} {
+		r := httptest.NewRequest("POST", "https://example.com/hooks/deploy", nil)
+		r.TLS = tt.state
+		if tt.state == nil {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		rule := MatchRule{Type: "client-cert", ClientCert: tt.opts}
+
+		ok, err := rule.Evaluate(&Request{RawRequest: r})
+		if ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("%s failed:\nexpected {ok:%v, err:%v}\ngot {ok:%v, err:%v}", tt.desc, tt.ok, tt.err, ok, err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ConfigError describes an invalid field of a hook definition.
+type ConfigError struct {
+	HookID string
+	Path   string
+	Err    error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("hook %s: %s: %v", e.HookID, e.Path, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigErrors is a list of errors found while compiling hooks.
+type ConfigErrors []*ConfigError
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// matchRuleTypes lists the supported match rule types.
+var matchRuleTypes = []string{
+	MatchValue, MatchRegex,
+	MatchHMACSHA1, MatchHMACSHA256, MatchHMACSHA512,
+	MatchHashSHA1, MatchHashSHA256, MatchHashSHA512,
+	IPWhitelist, ScalrSignature, TimeWindowRule, MatchJWT,
+	MatchEd25519, MatchRSASHA256, MatchECDSASHA256,
+	MatchHMACTimestamped, MatchHMAC, StandardWebhooks, HTTPMessageSignature,
+	MatchBasicAuth, MatchBearerToken, MatchClientCert,
+}
+
+// argumentSources lists the supported argument sources.
+var argumentSources = []string{
+	SourceHeader, SourceQuery, SourceQueryAlias, SourcePayload, SourceRawRequestBody,
+	SourceRequest, SourceString, SourceEntirePayload, SourceEntireQuery,
+	SourceEntireHeaders, SourceJWTClaim, SourceClientCert, SourcePath, SourceCookie,
+	SourceTemplate,
+}
+
+// requestKeys lists the supported keys of the request source.
+var requestKeys = []string{
+	RequestMethod, RequestRemoteAddr, RequestHost, RequestPath, RequestRawQuery,
+	RequestScheme, RequestProto, RequestContentLength, RequestContentType,
+	RequestUserAgent, RequestTLSVersion, RequestID, RequestHookID,
+}
+
+// httpMethodRegexp matches valid HTTP method tokens.
+var httpMethodRegexp = regexp.MustCompile("^[A-Z0-9!#$%&'*+.^_`|~-]+$")
+
+// hookCompiler collects the errors found while compiling a hook.
+type hookCompiler struct {
+	hookID string
+	errs   ConfigErrors
+}
+
+func (c *hookCompiler) check(path string, err error) {
+	if err != nil {
+		c.errs = append(c.errs, &ConfigError{HookID: c.hookID, Path: path, Err: err})
+	}
+}
+
+func (c *hookCompiler) errorf(path, format string, args ...interface{}) {
+	c.check(path, fmt.Errorf(format, args...))
+}
+
+// compile checks the hook definitions and prepares them for evaluation:
+// regular expressions, expressions and IP ranges are compiled, HTTP methods
+// are normalized, and overlapping path patterns are rejected.  All errors are
+// returned as ConfigErrors.
+func (h *Hooks) compile() error {
+	var errs ConfigErrors
+
+	for i := range *h {
+		c := &hookCompiler{hookID: (*h)[i].ID}
+		if c.hookID == "" {
+			c.hookID = fmt.Sprintf("#%d", i)
+		}
+
+		c.hook(&(*h)[i])
+
+		for j := 0; j < i; j++ {
+			if (*h)[i].OverlapsPath(&(*h)[j]) {
+				c.errorf("path", "path %q overlaps the path %q of hook %s", (*h)[i].Path, (*h)[j].Path, (*h)[j].ID)
+			}
+		}
+
+		errs = append(errs, c.errs...)
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (c *hookCompiler) hook(h *Hook) {
+	if h.ID == "" {
+		c.errorf("id", "hook ID must not be empty")
+	}
+
+	if h.ResponseMessageTemplate {
+		var err error
+
+		h.responseMessage, err = parseRequestTemplate("response-message", h.ResponseMessage)
+		c.check("response-message", err)
+	}
+
+	if h.Path != "" {
+		var err error
+
+		h.pathPattern, err = parsePathPattern(h.Path)
+		c.check("path", err)
+	}
+
+	for i, m := range h.HTTPMethods {
+		m = strings.ToUpper(strings.TrimSpace(m))
+		if !httpMethodRegexp.MatchString(m) {
+			c.errorf(fmt.Sprintf("http-methods[%d]", i), "invalid HTTP method %q", h.HTTPMethods[i])
+		}
+
+		h.HTTPMethods[i] = m
+	}
+
+	for _, l := range []struct {
+		path string
+		args []Argument
+	}{
+		{"pass-arguments-to-command", h.PassArgumentsToCommand},
+		{"pass-environment-to-command", h.PassEnvironmentToCommand},
+		{"pass-file-to-command", h.PassFileToCommand},
+		{"parse-parameters-as-json", h.JSONStringParameters},
+	} {
+		for i := range l.args {
+			c.argument(fmt.Sprintf("%s[%d]", l.path, i), &l.args[i])
+		}
+	}
+
+	for i, a := range h.JSONStringParameters {
+		switch a.Source {
+		case SourceHeader, SourcePayload, SourceQuery, SourceQueryAlias:
+		default:
+			c.errorf(fmt.Sprintf("parse-parameters-as-json[%d].source", i), "source %q can not be parsed as JSON", a.Source)
+		}
+	}
+
+	if h.MaxBodySize < 0 {
+		c.errorf("max-body-size", "max-body-size must not be negative")
+	}
+
+	if h.MaxDecompressedBodySize < 0 {
+		c.errorf("max-decompressed-body-size", "max-decompressed-body-size must not be negative")
+	}
+
+	if h.TriggerRule != nil {
+		c.rules("trigger-rule", h.TriggerRule)
+	}
+
+	if rl := h.RateLimit; rl != nil {
+		_, _, err := rl.Limits()
+		c.check("rate-limit", err)
+
+		switch rl.Key {
+		case "", RateLimitGlobal, RateLimitRemoteAddr:
+		case RateLimitParameter:
+			if rl.Parameter == nil {
+				c.errorf("rate-limit.parameter", "parameter is required for key %q", rl.Key)
+			} else {
+				c.argument("rate-limit.parameter", rl.Parameter)
+			}
+		default:
+			c.errorf("rate-limit.key", "invalid rate-limit key %q", rl.Key)
+		}
+	}
+
+	if rp := h.ReplayProtection; rp != nil {
+		_, err := rp.Duration()
+		c.check("replay-protection.ttl", err)
+		c.argument("replay-protection.id", &rp.ID)
+	}
+}
+
+// argument checks the source, name and transforms of an argument.
+func (c *hookCompiler) argument(path string, a *Argument) {
+	switch {
+	case a.Source == "":
+		c.errorf(path+".source", "source is required")
+
+	case !containsString(argumentSources, a.Source):
+		c.errorf(path+".source", "unknown source %q", a.Source)
+
+	case a.Source == SourceRequest && !containsString(requestKeys, strings.ToLower(a.Name)):
+		c.errorf(path+".name", "unsupported request key %q", a.Name)
+
+	case a.Source == SourceTemplate:
+		var err error
+
+		a.template, err = parseRequestTemplate("template", a.Name)
+		c.check(path+".name", err)
+	}
+
+	for i := range a.Transform {
+		c.check(fmt.Sprintf("%s.transform[%d]", path, i), a.Transform[i].compile())
+	}
+}
+
+// rules checks a rule and its sub rules.
+func (c *hookCompiler) rules(path string, r *Rules) {
+	var kinds []string
+
+	if r.And != nil {
+		kinds = append(kinds, "and")
+
+		for i := range *r.And {
+			c.rules(fmt.Sprintf("%s.and[%d]", path, i), &(*r.And)[i])
+		}
+	}
+
+	if r.Or != nil {
+		kinds = append(kinds, "or")
+
+		for i := range *r.Or {
+			c.rules(fmt.Sprintf("%s.or[%d]", path, i), &(*r.Or)[i])
+		}
+	}
+
+	if r.Not != nil {
+		kinds = append(kinds, "not")
+		c.rules(path+".not", (*Rules)(r.Not))
+	}
+
+	if r.Match != nil {
+		kinds = append(kinds, "match")
+		c.match(path+".match", r.Match)
+	}
+
+	if r.Expr != nil {
+		kinds = append(kinds, "expr")
+		c.check(path+".expr", r.Expr.compile())
+	}
+
+	switch len(kinds) {
+	case 0:
+		c.errorf(path, "rule must be one of and, or, not, match or expr")
+	case 1:
+	default:
+		c.errorf(path, "rule must have only one of %s", strings.Join(kinds, ", "))
+	}
+}
+
+// match checks a match rule and compiles its regular expression and IP
+// ranges.
+func (c *hookCompiler) match(path string, r *MatchRule) {
+	if !containsString(matchRuleTypes, r.Type) {
+		c.errorf(path+".type", "unknown match type %q", r.Type)
+		return
+	}
+
+	switch r.Type {
+	case MatchValue, MatchRegex, MatchHMACSHA1, MatchHMACSHA256, MatchHMACSHA512,
+		MatchHashSHA1, MatchHashSHA256, MatchHashSHA512, MatchJWT,
+		MatchEd25519, MatchRSASHA256, MatchECDSASHA256, MatchHMACTimestamped, MatchHMAC:
+		c.argument(path+".parameter", &r.Parameter)
+
+	case MatchBasicAuth, MatchBearerToken:
+		if r.Parameter.Source != "" {
+			c.argument(path+".parameter", &r.Parameter)
+		}
+	}
+
+	c.secrets(path, r)
+
+	switch r.SignedBody {
+	case "", SignedBodyDecompressed, SignedBodyCompressed:
+	default:
+		c.errorf(path+".signed-body", "invalid signed-body %q", r.SignedBody)
+	}
+
+	switch r.Type {
+	case MatchRegex:
+		var err error
+
+		r.regex, err = regexp.Compile(r.Regex)
+		c.check(path+".regex", err)
+
+	case IPWhitelist:
+		c.check(path, r.compileIPRanges())
+
+		if r.IPListFile != "" {
+			_, err := ipListFiles.Get(r.IPListFile)
+			c.check(path+".ip-list-file", err)
+		}
+
+		if r.DenyIPListFile != "" {
+			_, err := ipListFiles.Get(r.DenyIPListFile)
+			c.check(path+".deny-ip-list-file", err)
+		}
+
+	case TimeWindowRule:
+		if r.TimeWindow == nil {
+			c.errorf(path+".time-window", "time-window rule is missing the time-window definition")
+			break
+		}
+
+		c.check(path+".time-window", r.TimeWindow.compile())
+
+	case MatchJWT:
+		opts := r.JWT
+		if opts == nil {
+			opts = &JWTOptions{}
+		}
+
+		// Secret files and lists are checked separately, so any non-empty
+		// secret stands in for them here.
+		secret := r.Secret
+		if r.SecretFile != "" || len(r.Secrets) != 0 {
+			secret = "*"
+		}
+
+		_, err := opts.keys(secret)
+		c.check(path+".jwt", err)
+
+		if opts.Leeway != "" {
+			_, err := time.ParseDuration(opts.Leeway)
+			c.check(path+".jwt.leeway", err)
+		}
+
+	case MatchEd25519, MatchRSASHA256, MatchECDSASHA256:
+		keys, err := r.publicKeys()
+		if err == nil && len(keys) == 0 {
+			err = errors.New("public-key or public-key-file is required")
+		}
+		c.check(path, err)
+		c.encoding(path, r)
+
+	case MatchHMACTimestamped, MatchHMAC:
+		_, err := hmacHash(r.Algorithm)
+		c.check(path+".algorithm", err)
+
+		_, err = r.tolerance()
+		c.check(path+".tolerance", err)
+
+		if r.SignedContent != "" {
+			var err error
+
+			r.signedContentTemplate, err = parseSignedContent(r.SignedContent)
+			c.check(path+".signed-content", err)
+		}
+
+		if r.Timestamp != nil {
+			c.argument(path+".timestamp", r.Timestamp)
+		}
+
+		c.encoding(path, r)
+
+	case StandardWebhooks:
+		_, err := r.tolerance()
+		c.check(path+".tolerance", err)
+
+	case HTTPMessageSignature:
+		_, err := r.tolerance()
+		c.check(path+".tolerance", err)
+
+		if r.KeyDir == "" {
+			c.errorf(path+".key-dir", "key-dir is required")
+		}
+
+	case MatchBasicAuth, MatchBearerToken:
+		_, err := r.credentials()
+		c.check(path, err)
+
+	case MatchClientCert:
+		if r.ClientCert == nil {
+			c.errorf(path+".client-cert", "client-cert options are required")
+		}
+	}
+}
+
+// secrets checks the secret file and the secrets list of a match rule.
+func (c *hookCompiler) secrets(path string, r *MatchRule) {
+	if r.SecretFile == "" && len(r.Secrets) == 0 {
+		return
+	}
+
+	if !r.usesSecret() {
+		c.errorf(path, "match type %q does not use secrets", r.Type)
+		return
+	}
+
+	if r.SecretFile != "" {
+		_, err := readSecretFile(r.SecretFile)
+		c.check(path+".secret-file", err)
+	}
+
+	for i, s := range r.Secrets {
+		p := fmt.Sprintf("%s.secrets[%d]", path, i)
+
+		_, err := s.notAfter()
+		c.check(p+".not-after", err)
+
+		_, err = s.values()
+		c.check(p, err)
+	}
+}
+
+// encoding checks the signature encoding of a match rule.
+func (c *hookCompiler) encoding(path string, r *MatchRule) {
+	if _, err := EncodeSignature(nil, r.Encoding); err != nil {
+		c.check(path+".encoding", err)
+	}
+}
@@ -0,0 +1,133 @@
+package hook
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromFileCompile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`[
+		{
+			"id": "deploy",
+			"http-methods": [" post ", "Put"],
+			"trigger-rule": {"match": {"type": "regex", "regex": "^refs/heads/(main|master)$", "parameter": {"source": "payload", "name": "ref"}}}
+		},
+		{
+			"id": "signed",
+			"trigger-rule": {"match": {"type": "payload-hmac", "secret": "s", "signed-content": "{{.Body}}", "parameter": {"source": "header", "name": "X-Signature"}}}
+		},
+		{
+			"id": "scheduled",
+			"trigger-rule": {"match": {"type": "time-window", "time-window": {"days": ["sat"], "hours": ["09:00-17:00"], "timezone": "Europe/Berlin"}}}
+		}
+	]`)
+
+	var hooks Hooks
+	if err := hooks.LoadFromFile(file, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if m := hooks[0].HTTPMethods; len(m) != 2 || m[0] != "POST" || m[1] != "PUT" {
+		t.Errorf("failed to normalize HTTP methods: %q", m)
+	}
+
+	if hooks[0].TriggerRule.Match.regex == nil {
+		t.Error("regex should be compiled when loading hooks")
+	}
+
+	if hooks[1].TriggerRule.Match.signedContentTemplate == nil {
+		t.Error("signed-content template should be compiled when loading hooks")
+	}
+
+	if w := hooks[2].TriggerRule.Match.TimeWindow; !w.compiled || w.loc.String() != "Europe/Berlin" || len(w.hours) != 1 {
+		t.Error("time-window should be compiled when loading hooks")
+	}
+
+	write(`[
+		{
+			"id": "deploy",
+			"path": "deploy//{env}",
+			"http-methods": ["POST", "GET /"],
+			"pass-arguments-to-command": [{"source": "payload", "name": "ref"}, {"source": "body"}],
+			"pass-environment-to-command": [{"source": "request", "name": "host-name"}, {"source": "payload", "name": "ref", "transform": ["trim", "reverse", {"type": "regex-extract", "regex": "^refs/(.*)$", "group": 2}]}, {"source": "template", "name": "{{ .Payload.ref"}],
+			"parse-parameters-as-json": [{"source": "string", "name": "{}"}],
+			"max-body-size": -1,
+			"trigger-rule": {
+				"and": [
+					{"match": {"type": "regex", "regex": "(", "parameter": {"source": "payload", "name": "ref"}}},
+					{"match": {"type": "value", "value": "x"}},
+					{"or": [
+						{"match": {"type": "payload-hmac-sha265", "secret": "s", "parameter": {"source": "header", "name": "X-Signature"}}},
+						{"match": {"type": "payload-hmac", "algorithm": "md5", "tolerance": "5", "secret": "s", "parameter": {"source": "header", "name": "X-Signature"}}}
+					]},
+					{"not": {}},
+					{"match": {"type": "value", "value": "x", "parameter": {"source": "url", "name": "a"}}, "expr": "true"},
+					{"match": {"type": "basic-auth", "credentials": ["alice:password"]}},
+					{"match": {"type": "time-window", "time-window": {"days": ["sun"], "hours": ["bogus"]}}}
+				]
+			},
+			"rate-limit": {"requests": 0, "key": "user"}
+		},
+		{
+			"trigger-rule": {"match": {"type": "ip-whitelist", "ip-range": "10.0.0.256"}}
+		},
+		{"id": "release", "path": "release/{version}"},
+		{"id": "release-latest", "path": "release/latest"}
+	]`)
+
+	hooks = nil
+
+	err := hooks.LoadFromFile(file, false)
+
+	var errs ConfigErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	expected := []string{
+		`hook deploy: path: invalid path "deploy//{env}": empty segment`,
+		`hook deploy: http-methods[1]: invalid HTTP method "GET /"`,
+		`hook deploy: pass-arguments-to-command[1].source: unknown source "body"`,
+		`hook deploy: pass-environment-to-command[0].name: unsupported request key "host-name"`,
+		`hook deploy: pass-environment-to-command[1].transform[1]: unknown transform type "reverse"`,
+		`hook deploy: pass-environment-to-command[1].transform[2]: regex "^refs/(.*)$" has no group 2`,
+		`hook deploy: pass-environment-to-command[2].name: invalid template: `,
+		`hook deploy: parse-parameters-as-json[0].source: source "string" can not be parsed as JSON`,
+		`hook deploy: max-body-size: max-body-size must not be negative`,
+		`hook deploy: trigger-rule.and[0].match.regex: error parsing regexp: missing closing ): ` + "`(`",
+		`hook deploy: trigger-rule.and[1].match.parameter.source: source is required`,
+		`hook deploy: trigger-rule.and[2].or[0].match.type: unknown match type "payload-hmac-sha265"`,
+		`hook deploy: trigger-rule.and[2].or[1].match.algorithm: unsupported HMAC algorithm "md5"`,
+		`hook deploy: trigger-rule.and[2].or[1].match.tolerance: invalid tolerance "5"`,
+		`hook deploy: trigger-rule.and[3].not: rule must be one of and, or, not, match or expr`,
+		`hook deploy: trigger-rule.and[4]: rule must have only one of match, expr`,
+		`hook deploy: trigger-rule.and[5].match: invalid credential: unsupported hash format`,
+		`hook deploy: trigger-rule.and[6].match.time-window: invalid time-window hours "bogus": must be in HH:MM-HH:MM format`,
+		`hook deploy: rate-limit: rate-limit requests must be greater than zero`,
+		`hook deploy: rate-limit.key: invalid rate-limit key "user"`,
+		`hook #1: id: hook ID must not be empty`,
+		`hook #1: trigger-rule.match: invalid ip-range: invalid IP address: 10.0.0.256`,
+		`hook release-latest: path: path "release/latest" overlaps the path "release/{version}" of hook release`,
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(expected), len(errs), err)
+	}
+
+	for i := range expected {
+		if !strings.HasPrefix(errs[i].Error(), expected[i]) {
+			t.Errorf("error %d:\nexpected %s\ngot %s", i, expected[i], errs[i])
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package hook
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Constants for the body of a request verified by signature rules.
+const (
+	SignedBodyDecompressed string = "decompressed"
+	SignedBodyCompressed   string = "compressed"
+)
+
+// UnsupportedEncodingError describes a Content-Encoding that can not be
+// decompressed.
+type UnsupportedEncodingError struct {
+	Encoding string
+}
+
+func (e *UnsupportedEncodingError) Error() string {
+	return fmt.Sprintf("unsupported content encoding %q", e.Encoding)
+}
+
+// NewDecompressReader returns a reader decompressing r according to the
+// Content-Encoding header value encoding.  Multiple encodings are undone in
+// reverse order.  The supported encodings are gzip, deflate and identity.
+func NewDecompressReader(r io.Reader, encoding string) (io.ReadCloser, error) {
+	encodings := strings.Split(encoding, ",")
+
+	rc := io.NopCloser(r)
+
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+
+		switch e := strings.ToLower(strings.TrimSpace(encodings[i])); e {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			rc, err = gzip.NewReader(rc)
+		case "deflate":
+			rc, err = newDeflateReader(rc)
+		default:
+			return nil, &UnsupportedEncodingError{Encoding: e}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing %s body: %w", strings.TrimSpace(encodings[i]), err)
+		}
+	}
+
+	return rc, nil
+}
+
+// newDeflateReader returns a reader for the deflate encoding, which is zlib
+// wrapped deflate data.  Since some senders omit the zlib wrapper, raw
+// deflate data is accepted as well.
+func newDeflateReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,120 @@
+package hook
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+func compressBody(t *testing.T, encoding string, data []byte) []byte {
+	var buf bytes.Buffer
+
+	var w io.WriteCloser
+
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "zlib":
+		w = zlib.NewWriter(&buf)
+	case "flate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestNewDecompressReader(t *testing.T) {
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+
+	for _, tt := range []struct {
+		desc, encoding string
+		body           []byte
+		ok             bool
+	}{
+		{"gzip", "gzip", compressBody(t, "gzip", payload), true},
+		{"x-gzip", "X-Gzip", compressBody(t, "gzip", payload), true},
+		{"deflate", "deflate", compressBody(t, "zlib", payload), true},
+		{"raw deflate", "deflate", compressBody(t, "flate", payload), true},
+		{"identity", "identity", payload, true},
+		{"multiple encodings", "deflate, gzip", compressBody(t, "gzip", compressBody(t, "zlib", payload)), true},
+		// failures
+		{"invalid gzip", "gzip", payload, false},
+		{"wrong order", "gzip, deflate", compressBody(t, "gzip", compressBody(t, "zlib", payload)), false},
+	} {
+		var data []byte
+
+		rc, err := NewDecompressReader(bytes.NewReader(tt.body), tt.encoding)
+		if err == nil {
+			data, err = io.ReadAll(rc)
+		}
+
+		if (err == nil) != tt.ok || (tt.ok && !bytes.Equal(data, payload)) {
+			t.Errorf("%s failed:\nexpected {data:%q, ok:%v}\ngot {data:%q, err:%v}", tt.desc, payload, tt.ok, data, err)
+		}
+	}
+
+	var encodingErr *UnsupportedEncodingError
+
+	if _, err := NewDecompressReader(bytes.NewReader(payload), "zstd"); !errors.As(err, &encodingErr) || encodingErr.Encoding != "zstd" {
+		t.Errorf("expected unsupported encoding error for zstd, got %v", err)
+	}
+}
+
+func TestMatchRuleSignedBody(t *testing.T) {
+	payload := []byte(`{"ref": "refs/heads/main"}`)
+	compressed := compressBody(t, "gzip", payload)
+
+	sign := func(data []byte) string {
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write(data)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	for _, tt := range []struct {
+		desc, signedBody string
+		signature        string
+		ok               bool
+	}{
+		{"default", "", sign(payload), true},
+		{"decompressed", SignedBodyDecompressed, sign(payload), true},
+		{"compressed", SignedBodyCompressed, sign(compressed), true},
+		{"compressed mismatch", SignedBodyCompressed, sign(payload), false},
+		{"decompressed mismatch", SignedBodyDecompressed, sign(compressed), false},
+	} {
+		req := &Request{
+			Body:           payload,
+			CompressedBody: compressed,
+			Headers:        map[string]interface{}{"X-Signature": tt.signature},
+		}
+
+		rule := MatchRule{
+			Type:       MatchHMACSHA256,
+			Secret:     "secret",
+			SignedBody: tt.signedBody,
+			Parameter:  Argument{Source: SourceHeader, Name: "X-Signature"},
+		}
+
+		if ok, _ := rule.Evaluate(req); ok != tt.ok {
+			t.Errorf("%s failed:\nexpected %v\ngot %v", tt.desc, tt.ok, ok)
+		}
+
+		if !bytes.Equal(req.Body, payload) {
+			t.Errorf("%s failed: body not restored after evaluation", tt.desc)
+		}
+	}
+}
@@ -0,0 +1,1162 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/textproto"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ExprRule is a boolean expression over the request, such as
+//
+//	payload.ref == "refs/heads/main" && headers["X-GitHub-Event"] in ["push", "release"]
+//
+// The expression is compiled and type-checked when the hooks are loaded.
+type ExprRule struct {
+	Source string
+
+	root exprNode
+
+	// redacted is the Source shown in traces.
+	redacted string
+}
+
+// CompileExpr compiles the given expression.
+func CompileExpr(src string) (*ExprRule, error) {
+	root, err := parseExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExprRule{Source: src, root: root, redacted: redactExprSource(src)}, nil
+}
+
+// UnmarshalJSON reads the expression from a JSON string.  The expression is
+// compiled when the hooks are loaded.
+func (r *ExprRule) UnmarshalJSON(data []byte) error {
+	var src string
+	if err := json.Unmarshal(data, &src); err != nil {
+		return errors.New("expr rule must be a string")
+	}
+
+	*r = ExprRule{Source: src}
+
+	return nil
+}
+
+// compile compiles the expression for evaluation.
+func (r *ExprRule) compile() error {
+	root, err := parseExpr(r.Source)
+	if err != nil {
+		return err
+	}
+
+	r.root = root
+	r.redacted = redactExprSource(r.Source)
+
+	return nil
+}
+
+// MarshalJSON returns the source of the expression as a JSON string.
+func (r ExprRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Source)
+}
+
+// Evaluate ExprRule will return the value of the expression.  Missing values
+// evaluate to null, which is treated as false.
+func (r ExprRule) Evaluate(req *Request) (bool, error) {
+	req.AuthChallenge = ""
+
+	root := r.root
+	if root == nil {
+		var err error
+		if root, err = parseExpr(r.Source); err != nil {
+			return false, err
+		}
+	}
+
+	v, err := root.eval(req)
+	if err != nil {
+		return false, fmt.Errorf("expr: %w", err)
+	}
+
+	b, err := exprBool(v)
+	if err != nil {
+		return false, fmt.Errorf("expr: %w", err)
+	}
+
+	return b, nil
+}
+
+// exprType is the static type of an expression.  Values read from the
+// request have type exprAny and are checked at evaluation time.
+type exprType int
+
+const (
+	exprAny exprType = iota
+	exprBoolType
+	exprNumberType
+	exprStringType
+	exprListType
+)
+
+func (t exprType) String() string {
+	switch t {
+	case exprBoolType:
+		return "bool"
+	case exprNumberType:
+		return "number"
+	case exprStringType:
+		return "string"
+	case exprListType:
+		return "list"
+	}
+
+	return "any"
+}
+
+// accepts reports whether a value of type u may be used where type t is
+// expected.
+func (t exprType) accepts(u exprType) bool {
+	return t == exprAny || u == exprAny || t == u
+}
+
+// exprNode is a node of a compiled expression.
+type exprNode interface {
+	eval(req *Request) (interface{}, error)
+	typ() exprType
+}
+
+type exprLiteral struct {
+	value interface{}
+	t     exprType
+}
+
+func (n *exprLiteral) eval(*Request) (interface{}, error) { return n.value, nil }
+func (n *exprLiteral) typ() exprType                      { return n.t }
+
+type exprList struct {
+	items []exprNode
+}
+
+func (n *exprList) eval(req *Request) (interface{}, error) {
+	l := make([]interface{}, len(n.items))
+
+	for i, item := range n.items {
+		v, err := item.eval(req)
+		if err != nil {
+			return nil, err
+		}
+
+		l[i] = v
+	}
+
+	return l, nil
+}
+
+func (n *exprList) typ() exprType { return exprListType }
+
+// exprRequestValues is the value of the request variable, whose keys are
+// resolved like the request argument source.
+type exprRequestValues struct {
+	req *Request
+}
+
+type exprVariable struct {
+	name string
+}
+
+func (n *exprVariable) eval(req *Request) (interface{}, error) {
+	switch n.name {
+	case "payload":
+		return exprMap(req.Payload), nil
+	case "headers":
+		return exprMap(req.Headers), nil
+	case "query":
+		return exprMap(req.Query), nil
+	case "path":
+		return exprMap(req.PathParameters), nil
+	}
+
+	return exprRequestValues{req}, nil
+}
+
+func (n *exprVariable) typ() exprType { return exprAny }
+
+// exprMap returns m as a value, so that a nil map evaluates to null.
+func exprMap(m map[string]interface{}) interface{} {
+	if m == nil {
+		return nil
+	}
+
+	return m
+}
+
+type exprIndex struct {
+	x, key exprNode
+	header bool
+}
+
+func (n *exprIndex) eval(req *Request) (interface{}, error) {
+	x, err := n.x.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := n.key.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := x.(type) {
+	case nil:
+		return nil, nil
+
+	case map[string]interface{}:
+		k, err := exprString(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.header {
+			k = textproto.CanonicalMIMEHeaderKey(k)
+		}
+
+		return exprValue(x[k]), nil
+
+	case []interface{}:
+		f, ok := key.(float64)
+		if !ok {
+			if s, isString := key.(string); isString {
+				f, err = strconv.ParseFloat(s, 64)
+				ok = err == nil
+			}
+		}
+
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("invalid list index %s", exprTypeName(key))
+		}
+
+		// Compare as floats, since huge indexes overflow int.
+		if f < 0 || f >= float64(len(x)) {
+			return nil, nil
+		}
+
+		return exprValue(x[int(f)]), nil
+
+	case exprRequestValues:
+		k, err := exprString(key)
+		if err != nil {
+			return nil, err
+		}
+
+		a := Argument{Source: SourceRequest, Name: k}
+
+		return a.Get(x.req)
+	}
+
+	return nil, fmt.Errorf("cannot index %s", exprTypeName(x))
+}
+
+func (n *exprIndex) typ() exprType { return exprAny }
+
+type exprNot struct {
+	x exprNode
+}
+
+func (n *exprNot) eval(req *Request) (interface{}, error) {
+	v, err := n.x.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := exprBool(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return !b, nil
+}
+
+func (n *exprNot) typ() exprType { return exprBoolType }
+
+type exprLogical struct {
+	and  bool
+	l, r exprNode
+}
+
+func (n *exprLogical) eval(req *Request) (interface{}, error) {
+	v, err := n.l.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := exprBool(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if b != n.and {
+		return b, nil
+	}
+
+	if v, err = n.r.eval(req); err != nil {
+		return nil, err
+	}
+
+	return exprBool(v)
+}
+
+func (n *exprLogical) typ() exprType { return exprBoolType }
+
+type exprCompare struct {
+	op   string
+	l, r exprNode
+}
+
+func (n *exprCompare) eval(req *Request) (interface{}, error) {
+	l, err := n.l.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := n.r.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return exprEqual(l, r), nil
+	case "!=":
+		return !exprEqual(l, r), nil
+	case "in":
+		return exprIn(l, r)
+	}
+
+	if l == nil || r == nil {
+		return false, nil
+	}
+
+	var c int
+
+	switch {
+	case isExprNumber(l) && isExprNumber(r):
+		a, b := l.(float64), r.(float64)
+		if a < b {
+			c = -1
+		} else if a > b {
+			c = 1
+		}
+	case isExprString(l) && isExprString(r):
+		c = strings.Compare(l.(string), r.(string))
+	default:
+		return nil, fmt.Errorf("cannot compare %s and %s", exprTypeName(l), exprTypeName(r))
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+
+	return c >= 0, nil
+}
+
+func (n *exprCompare) typ() exprType { return exprBoolType }
+
+type exprCall struct {
+	name string
+	fn   *exprFunc
+	args []exprNode
+}
+
+func (n *exprCall) eval(req *Request) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+
+	for i, arg := range n.args {
+		v, err := arg.eval(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.fn.params[i] == exprStringType {
+			if v, err = exprString(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", n.name, err)
+			}
+		}
+
+		args[i] = v
+	}
+
+	v, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+
+	return v, nil
+}
+
+func (n *exprCall) typ() exprType { return n.fn.result }
+
+type exprMatch struct {
+	x  exprNode
+	re *regexp.Regexp
+}
+
+func (n *exprMatch) eval(req *Request) (interface{}, error) {
+	v, err := n.x.eval(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if v == nil {
+		return false, nil
+	}
+
+	s, err := exprString(v)
+	if err != nil {
+		return nil, fmt.Errorf("matches: %w", err)
+	}
+
+	return n.re.MatchString(s), nil
+}
+
+func (n *exprMatch) typ() exprType { return exprBoolType }
+
+// exprFunc is a helper function available in expressions.  Arguments of
+// string parameters are converted to strings before the call.
+type exprFunc struct {
+	params []exprType
+	result exprType
+	call   func(args []interface{}) (interface{}, error)
+}
+
+// stringFunc returns a helper function taking and returning strings.
+func stringFunc(f func(string) string) *exprFunc {
+	return &exprFunc{
+		params: []exprType{exprStringType},
+		result: exprStringType,
+		call: func(args []interface{}) (interface{}, error) {
+			return f(args[0].(string)), nil
+		},
+	}
+}
+
+// stringPredicate returns a helper function reporting whether two strings
+// satisfy f.
+func stringPredicate(f func(string, string) bool) *exprFunc {
+	return &exprFunc{
+		params: []exprType{exprStringType, exprStringType},
+		result: exprBoolType,
+		call: func(args []interface{}) (interface{}, error) {
+			return f(args[0].(string), args[1].(string)), nil
+		},
+	}
+}
+
+// exprFuncs are the helper functions available in expressions, besides
+// matches, which is compiled separately.
+var exprFuncs = map[string]*exprFunc{
+	"contains":   stringPredicate(strings.Contains),
+	"startsWith": stringPredicate(strings.HasPrefix),
+	"endsWith":   stringPredicate(strings.HasSuffix),
+	"lower":      stringFunc(strings.ToLower),
+	"upper":      stringFunc(strings.ToUpper),
+	"trim":       stringFunc(strings.TrimSpace),
+	"split": {
+		params: []exprType{exprStringType, exprStringType},
+		result: exprListType,
+		call: func(args []interface{}) (interface{}, error) {
+			parts := strings.Split(args[0].(string), args[1].(string))
+			l := make([]interface{}, len(parts))
+			for i := range parts {
+				l[i] = parts[i]
+			}
+			return l, nil
+		},
+	},
+	"string": {
+		params: []exprType{exprStringType},
+		result: exprStringType,
+		call: func(args []interface{}) (interface{}, error) {
+			return args[0], nil
+		},
+	},
+	"number": {
+		params: []exprType{exprAny},
+		result: exprNumberType,
+		call: func(args []interface{}) (interface{}, error) {
+			switch v := args[0].(type) {
+			case nil, float64:
+				return v, nil
+			case string:
+				f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid number %q", v)
+				}
+				return f, nil
+			}
+			return nil, fmt.Errorf("cannot convert %s to number", exprTypeName(args[0]))
+		},
+	},
+	"len": {
+		params: []exprType{exprAny},
+		result: exprNumberType,
+		call: func(args []interface{}) (interface{}, error) {
+			switch v := args[0].(type) {
+			case nil:
+				return float64(0), nil
+			case string:
+				return float64(len(v)), nil
+			case []interface{}:
+				return float64(len(v)), nil
+			case map[string]interface{}:
+				return float64(len(v)), nil
+			}
+			return nil, fmt.Errorf("cannot take length of %s", exprTypeName(args[0]))
+		},
+	},
+	"has": {
+		params: []exprType{exprAny},
+		result: exprBoolType,
+		call: func(args []interface{}) (interface{}, error) {
+			return args[0] != nil, nil
+		},
+	},
+	"abs": {
+		params: []exprType{exprNumberType},
+		result: exprNumberType,
+		call: func(args []interface{}) (interface{}, error) {
+			f, ok := args[0].(float64)
+			if !ok {
+				if args[0] == nil {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("expected number, got %s", exprTypeName(args[0]))
+			}
+			return math.Abs(f), nil
+		},
+	},
+}
+
+// exprValue converts a value read from the request to an expression value:
+// numbers become float64.
+func exprValue(v interface{}) interface{} {
+	switch n := v.(type) {
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	}
+
+	return v
+}
+
+func isExprNumber(v interface{}) bool {
+	_, ok := v.(float64)
+	return ok
+}
+
+func isExprString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+// exprTypeName returns the type name of a value for error messages.
+func exprTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", v)
+}
+
+// exprBool converts a value to a boolean.  Null is false.
+func exprBool(v interface{}) (bool, error) {
+	switch b := v.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return b, nil
+	}
+
+	return false, fmt.Errorf("expected bool, got %s", exprTypeName(v))
+}
+
+// exprString converts a scalar value to a string.  Null is the empty string.
+func exprString(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return s, nil
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	}
+
+	return "", fmt.Errorf("expected string, got %s", exprTypeName(v))
+}
+
+// exprEqual reports whether two values are equal.
+func exprEqual(a, b interface{}) bool {
+	if la, ok := a.([]interface{}); ok {
+		lb, ok := b.([]interface{})
+		if !ok || len(la) != len(lb) {
+			return false
+		}
+
+		for i := range la {
+			if !exprEqual(exprValue(la[i]), exprValue(lb[i])) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// exprIn reports whether a is an element of the list b, a key of the object b
+// or a substring of the string b.
+func exprIn(a, b interface{}) (bool, error) {
+	switch b := b.(type) {
+	case nil:
+		return false, nil
+
+	case []interface{}:
+		for _, v := range b {
+			if exprEqual(a, exprValue(v)) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+
+	case map[string]interface{}:
+		k, err := exprString(a)
+		if err != nil {
+			return false, err
+		}
+
+		_, ok := b[k]
+
+		return ok, nil
+
+	case string:
+		if a == nil {
+			return false, nil
+		}
+
+		s, err := exprString(a)
+		if err != nil {
+			return false, err
+		}
+
+		return strings.Contains(b, s), nil
+	}
+
+	return false, fmt.Errorf("cannot use in with %s", exprTypeName(b))
+}
+
+// Expression tokens.
+const (
+	exprEOF = iota
+	exprIdent
+	exprNumber
+	exprStringLit
+	exprPunct
+)
+
+type exprToken struct {
+	kind  int
+	text  string
+	value interface{}
+	pos   int
+}
+
+// exprPuncts lists the operators and punctuation, longest first.
+var exprPuncts = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+// lexExpr splits an expression into tokens.
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+
+			if j >= len(src) {
+				return nil, exprErrorf(i, "unterminated string")
+			}
+
+			lit := src[i : j+1]
+			if c == '\'' {
+				lit = `"` + strings.ReplaceAll(strings.ReplaceAll(lit[1:len(lit)-1], `\'`, `'`), `"`, `\"`) + `"`
+			}
+
+			s, err := strconv.Unquote(lit)
+			if err != nil {
+				return nil, exprErrorf(i, "invalid string %s", src[i:j+1])
+			}
+
+			toks = append(toks, exprToken{exprStringLit, src[i : j+1], s, i})
+			i = j + 1
+
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i + 1
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+
+			// Only plain integers may follow a dot, so that payload.items.0.id
+			// is not read as payload.items followed by the number 0.0.
+			afterDot := len(toks) != 0 && toks[len(toks)-1].text == "."
+
+			if !afterDot && j+1 < len(src) && src[j] == '.' && src[j+1] >= '0' && src[j+1] <= '9' {
+				j++
+				for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+					j++
+				}
+			}
+
+			f, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, exprErrorf(i, "invalid number %s", src[i:j])
+			}
+
+			toks = append(toks, exprToken{exprNumber, src[i:j], f, i})
+			i = j
+
+		case isExprIdentStart(c):
+			j := i + 1
+			for j < len(src) && (isExprIdentStart(src[j]) || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+
+			toks = append(toks, exprToken{exprIdent, src[i:j], nil, i})
+			i = j
+
+		default:
+			var found bool
+
+			for _, p := range exprPuncts {
+				if strings.HasPrefix(src[i:], p) {
+					toks = append(toks, exprToken{exprPunct, p, nil, i})
+					i += len(p)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, exprErrorf(i, "unexpected character %q", c)
+			}
+		}
+	}
+
+	return append(toks, exprToken{exprEOF, "", nil, len(src)}), nil
+}
+
+func isExprIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// exprErrorf returns an error at the given offset of the expression.
+func exprErrorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("expr: column %d: %s", pos+1, fmt.Sprintf(format, args...))
+}
+
+type exprParser struct {
+	toks []exprToken
+	pos  int
+}
+
+// parseExpr compiles an expression, checking that it evaluates to a bool.
+func parseExpr(src string) (exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{toks: toks}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != exprEOF {
+		return nil, exprErrorf(t.pos, "unexpected %q", t.text)
+	}
+
+	if !exprBoolType.accepts(n.typ()) {
+		return nil, errors.New("expr: expression must evaluate to bool, not " + n.typ().String())
+	}
+
+	return n, nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.toks[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != exprEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given punctuation or keyword.
+func (p *exprParser) accept(text string) bool {
+	if t := p.peek(); (t.kind == exprPunct || t.kind == exprIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.accept(text) {
+		t := p.peek()
+		if t.kind == exprEOF {
+			return exprErrorf(t.pos, "expected %q, got end of expression", text)
+		}
+		return exprErrorf(t.pos, "expected %q, got %q", text, t.text)
+	}
+	return nil
+}
+
+// checkBool checks that the operand of a logical operator is a bool.
+func checkBool(op string, t exprToken, n exprNode) error {
+	if !exprBoolType.accepts(n.typ()) {
+		return exprErrorf(t.pos, "operand of %s must be bool, not %s", op, n.typ())
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if !p.accept("||") {
+			return l, nil
+		}
+
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkBool("||", t, l); err != nil {
+			return nil, err
+		}
+		if err := checkBool("||", t, r); err != nil {
+			return nil, err
+		}
+
+		l = &exprLogical{and: false, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	l, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if !p.accept("&&") {
+			return l, nil
+		}
+
+		r, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkBool("&&", t, l); err != nil {
+			return nil, err
+		}
+		if err := checkBool("&&", t, r); err != nil {
+			return nil, err
+		}
+
+		l = &exprLogical{and: true, l: l, r: r}
+	}
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+
+	switch {
+	case t.kind == exprPunct && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+	case t.kind == exprIdent && t.text == "in":
+	default:
+		return l, nil
+	}
+
+	p.next()
+
+	r, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	lt, rt := l.typ(), r.typ()
+
+	switch t.text {
+	case "==", "!=":
+		if !lt.accepts(rt) {
+			return nil, exprErrorf(t.pos, "cannot compare %s and %s", lt, rt)
+		}
+	case "in":
+		if !exprListType.accepts(rt) && !(rt == exprStringType && exprStringType.accepts(lt)) {
+			return nil, exprErrorf(t.pos, "cannot use in with %s and %s", lt, rt)
+		}
+	default:
+		if !lt.accepts(rt) || lt == exprBoolType || lt == exprListType || rt == exprBoolType || rt == exprListType {
+			return nil, exprErrorf(t.pos, "cannot compare %s and %s", lt, rt)
+		}
+	}
+
+	return &exprCompare{op: t.text, l: l, r: r}, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	t := p.peek()
+	if p.accept("!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkBool("!", t, x); err != nil {
+			return nil, err
+		}
+
+		return &exprNot{x}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+
+		var key exprNode
+
+		switch {
+		case p.accept("."):
+			k := p.next()
+			if k.kind != exprIdent && !(k.kind == exprNumber && k.value.(float64) >= 0) {
+				return nil, exprErrorf(k.pos, "expected field name after \".\"")
+			}
+			key = &exprLiteral{k.text, exprStringType}
+
+		case p.accept("["):
+			if key, err = p.parseOr(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if kt := key.typ(); kt != exprAny && kt != exprStringType && kt != exprNumberType {
+				return nil, exprErrorf(t.pos, "invalid index of type %s", kt)
+			}
+
+		default:
+			return x, nil
+		}
+
+		if xt := x.typ(); xt != exprAny && xt != exprListType {
+			return nil, exprErrorf(t.pos, "cannot index %s", xt)
+		}
+
+		v, isVariable := x.(*exprVariable)
+
+		x = &exprIndex{x: x, key: key, header: isVariable && v.name == "headers"}
+	}
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case exprNumber:
+		return &exprLiteral{t.value, exprNumberType}, nil
+
+	case exprStringLit:
+		return &exprLiteral{t.value, exprStringType}, nil
+
+	case exprIdent:
+		switch t.text {
+		case "true", "false":
+			return &exprLiteral{t.text == "true", exprBoolType}, nil
+		case "null":
+			return &exprLiteral{nil, exprAny}, nil
+		case "payload", "headers", "query", "path", "request":
+			return &exprVariable{t.text}, nil
+		}
+
+		if p.peek().text != "(" {
+			return nil, exprErrorf(t.pos, "unknown identifier %q", t.text)
+		}
+
+		return p.parseCall(t)
+
+	case exprPunct:
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expect(")")
+
+		case "[":
+			l := &exprList{}
+			for !p.accept("]") {
+				if len(l.items) != 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+
+				item, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+
+				l.items = append(l.items, item)
+			}
+			return l, nil
+		}
+	}
+
+	if t.kind == exprEOF {
+		return nil, exprErrorf(t.pos, "unexpected end of expression")
+	}
+
+	return nil, exprErrorf(t.pos, "unexpected %q", t.text)
+}
+
+// parseCall parses the arguments of a call to the named helper function.
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	p.next()
+
+	var args []exprNode
+
+	for !p.accept(")") {
+		if len(args) != 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, arg)
+	}
+
+	if name.text == "matches" {
+		if len(args) != 2 {
+			return nil, exprErrorf(name.pos, "matches expects 2 arguments, got %d", len(args))
+		}
+
+		lit, ok := args[1].(*exprLiteral)
+		if !ok || lit.t != exprStringType {
+			return nil, exprErrorf(name.pos, "matches expects a string literal pattern")
+		}
+
+		if at := args[0].typ(); at == exprListType {
+			return nil, exprErrorf(name.pos, "argument 1 of matches must be string, not %s", at)
+		}
+
+		re, err := regexp.Compile(lit.value.(string))
+		if err != nil {
+			return nil, exprErrorf(name.pos, "invalid pattern: %v", err)
+		}
+
+		return &exprMatch{x: args[0], re: re}, nil
+	}
+
+	fn, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, exprErrorf(name.pos, "unknown function %q", name.text)
+	}
+
+	if len(args) != len(fn.params) {
+		return nil, exprErrorf(name.pos, "%s expects %d arguments, got %d", name.text, len(fn.params), len(args))
+	}
+
+	for i, arg := range args {
+		if at := arg.typ(); !fn.params[i].accepts(at) {
+			return nil, exprErrorf(name.pos, "argument %d of %s must be %s, not %s", i+1, name.text, fn.params[i], at)
+		}
+	}
+
+	return &exprCall{name: name.text, fn: fn, args: args}, nil
+}
@@ -0,0 +1,163 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExprRule(t *testing.T) {
+	var payload map[string]interface{}
+
+	decoder := json.NewDecoder(strings.NewReader(`{
+		"ref": "refs/heads/main",
+		"size": 3,
+		"deleted": false,
+		"repository": {"full_name": "adnanh/webhook", "topics": ["go", "webhooks"]},
+		"commits": [{"id": "abc123", "message": "Fix build [deploy]"}]
+	}`))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{
+		Payload:    payload,
+		Headers:    map[string]interface{}{"X-Github-Event": "push", "Content-Type": "application/json"},
+		Query:      map[string]interface{}{"count": "12", "env": "Production ", "huge": "1e20", "negative": "-1e20"},
+		RawRequest: httptest.NewRequest("POST", "/hooks/deploy", nil),
+	}
+
+	for _, tt := range []struct {
+		expr string
+		ok   bool
+		err  bool
+	}{
+		{`payload.ref == "refs/heads/main" && headers["X-GitHub-Event"] in ["push","release"]`, true, false},
+		{`payload.ref == 'refs/heads/dev' || headers["x-github-event"] == "push"`, true, false},
+		{`payload.size == 3 && payload.size >= 2.5 && payload.size < 10`, true, false},
+		{`!payload.deleted && payload.missing == null && !has(payload.missing.nested)`, true, false},
+		{`payload.repository.full_name == "adnanh/webhook" && "go" in payload.repository.topics`, true, false},
+		{`payload.commits.0.id == "abc123" && payload["commits"][0]["id"] == "abc123"`, true, false},
+		{`contains(payload.commits.0.message, "[deploy]") && startsWith(payload.ref, "refs/") && endsWith(payload.ref, "/main")`, true, false},
+		{`matches(payload.ref, "^refs/heads/(main|master)$")`, true, false},
+		{`number(query.count) > 10 && len(payload.commits) == 1 && abs(-2) == 2`, true, false},
+		{`lower(trim(query.env)) == "production" && upper("a") == "A"`, true, false},
+		{`split(payload.repository.full_name, "/")[1] == "webhook" && string(payload.size) == "3"`, true, false},
+		{`"full_name" in payload.repository && "heads" in payload.ref`, true, false},
+		{`request.method == "POST" && request["remote-addr"] != ""`, true, false},
+		{`payload.commits[query.huge] == null && payload.commits[query.negative] == null && payload.commits[100000000000000000000] == null && payload.commits[-1] == null`, true, false},
+		// mismatches
+		{`payload.ref == "refs/heads/dev"`, false, false},
+		{`headers["X-GitHub-Event"] in ["release"]`, false, false},
+		{`payload.missing`, false, false},
+		{`payload.missing > 1`, false, false},
+		{`matches(payload.missing, ".*")`, false, false},
+		{`number(query.missing) > 1`, false, false},
+		// errors
+		{`payload.ref`, false, true},
+		{`payload.ref > 1`, false, true},
+		{`number(payload.ref) > 1`, false, true},
+		{`payload.ref.x == 1`, false, true},
+		{`request.unknown == ""`, false, true},
+	} {
+		rule, err := CompileExpr(tt.expr)
+		if err != nil {
+			t.Errorf("failed to compile %s: %v", tt.expr, err)
+			continue
+		}
+
+		ok, err := rule.Evaluate(req)
+		if ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("failed to evaluate %s:\nexpected {ok:%v, err:%v}\ngot {ok:%v, err:%v}", tt.expr, tt.ok, tt.err, ok, err)
+		}
+	}
+}
+
+func TestCompileExprErrors(t *testing.T) {
+	for _, tt := range []struct {
+		expr, err string
+	}{
+		{``, "column 1: unexpected end of expression"},
+		{`payload.ref ==`, "column 15: unexpected end of expression"},
+		{`paylod.ref == "main"`, `column 1: unknown identifier "paylod"`},
+		{`payload.ref == "main" &&`, "unexpected end of expression"},
+		{`payload.ref == "main`, "column 16: unterminated string"},
+		{`payload.ref # 1`, `column 13: unexpected character '#'`},
+		{`(payload.ref == "main"`, `expected ")", got end of expression`},
+		{`"main"`, "expression must evaluate to bool, not string"},
+		{`1 == "1"`, "column 3: cannot compare number and string"},
+		{`true < false`, "column 6: cannot compare bool and bool"},
+		{`"a" && true`, "column 5: operand of && must be bool, not string"},
+		{`!"a"`, "column 1: operand of ! must be bool, not string"},
+		{`1 in 2`, "column 3: cannot use in with number and number"},
+		{`"abc"[0] == "a"`, "column 6: cannot index string"},
+		{`unknown(payload.ref)`, `column 1: unknown function "unknown"`},
+		{`contains(payload.ref)`, "column 1: contains expects 2 arguments, got 1"},
+		{`startsWith(["a"], "a")`, "column 1: argument 1 of startsWith must be string, not list"},
+		{`matches(payload.ref, payload.pattern)`, "column 1: matches expects a string literal pattern"},
+		{`matches(payload.ref, "(")`, "column 1: invalid pattern"},
+		{`payload.ref == "a" == "b"`, `column 20: unexpected "=="`},
+	} {
+		_, err := CompileExpr(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("failed to reject %s:\nexpected error containing %q\ngot %v", tt.expr, tt.err, err)
+		}
+	}
+}
+
+func TestExprRuleLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.yaml")
+
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`
+- id: deploy
+  trigger-rule:
+    and:
+    - expr: payload.ref == "refs/heads/main"
+    - match:
+        type: value
+        value: push
+        parameter:
+          source: header
+          name: X-GitHub-Event
+`)
+
+	var hooks Hooks
+	if err := hooks.LoadFromFile(file, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if (*hooks[0].TriggerRule.And)[0].Expr.root == nil {
+		t.Error("expr rule should be compiled when loading hooks")
+	}
+
+	req := &Request{
+		Payload: map[string]interface{}{"ref": "refs/heads/main"},
+		Headers: map[string]interface{}{"X-Github-Event": "push"},
+	}
+
+	if ok, err := hooks[0].TriggerRule.Evaluate(req); !ok || err != nil {
+		t.Errorf("failed to evaluate loaded expr rule: ok: %v, err: %v", ok, err)
+	}
+
+	write(`
+- id: deploy
+  trigger-rule:
+    expr: payload.ref = "refs/heads/main"
+`)
+
+	hooks = nil
+	if err := hooks.LoadFromFile(file, false); err == nil || !strings.Contains(err.Error(), "hook deploy: trigger-rule.expr: expr: column 13") {
+		t.Errorf("expected compile error when loading hooks, got %v", err)
+	}
+}
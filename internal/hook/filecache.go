@@ -0,0 +1,65 @@
+package hook
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCache caches the parsed contents of files, re-reading a file whenever
+// its modification time or size changes.  This allows key and credential
+// files to be replaced without reloading the hooks.
+type fileCache struct {
+	parse func([]byte) (interface{}, error)
+
+	mu      sync.Mutex
+	entries map[string]*fileCacheEntry
+}
+
+type fileCacheEntry struct {
+	modTime time.Time
+	size    int64
+	value   interface{}
+}
+
+// newFileCache creates a fileCache using the given function to parse file
+// contents.
+func newFileCache(parse func([]byte) (interface{}, error)) *fileCache {
+	return &fileCache{
+		parse:   parse,
+		entries: make(map[string]*fileCacheEntry),
+	}
+}
+
+// Get returns the parsed contents of the file at path.
+func (c *fileCache) Get(path string) (interface{}, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[path]; ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e.value, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := c.parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	c.entries[path] = &fileCacheEntry{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		value:   v,
+	}
+
+	return v, nil
+}
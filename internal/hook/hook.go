@@ -15,7 +15,6 @@ import (
 	"hash"
 	"log"
 	"math"
-	"net"
 	"net/textproto"
 	"os"
 	"path"
@@ -41,6 +40,28 @@ const (
 	SourceEntirePayload  string = "entire-payload"
 	SourceEntireQuery    string = "entire-query"
 	SourceEntireHeaders  string = "entire-headers"
+	SourceJWTClaim       string = "jwt-claim"
+	SourceClientCert     string = "client-cert"
+	SourcePath           string = "path"
+	SourceCookie         string = "cookie"
+	SourceTemplate       string = "template"
+)
+
+// Constants for the keys of the request source
+const (
+	RequestMethod        string = "method"
+	RequestRemoteAddr    string = "remote-addr"
+	RequestHost          string = "host"
+	RequestPath          string = "path"
+	RequestRawQuery      string = "raw-query"
+	RequestScheme        string = "scheme"
+	RequestProto         string = "proto"
+	RequestContentLength string = "content-length"
+	RequestContentType   string = "content-type"
+	RequestUserAgent     string = "user-agent"
+	RequestTLSVersion    string = "tls-version"
+	RequestID            string = "request-id"
+	RequestHookID        string = "hook-id"
 )
 
 const (
@@ -171,19 +192,30 @@ func ExtractSignatures(source, prefix string) []string {
 }
 
 // ValidateMAC will verify that the expected mac for the given hash will match
-// the one provided.
-func ValidateMAC(payload []byte, mac hash.Hash, signatures []string) (string, error) {
+// one of the signatures provided, decoded using the given encoding (hex by
+// default).  The expected mac is returned in the same encoding.
+func ValidateMAC(payload []byte, mac hash.Hash, signatures []string, encoding string) (string, error) {
 	// Write the payload to the provided hash.
 	_, err := mac.Write(payload)
 	if err != nil {
 		return "", err
 	}
 
-	actualMAC := hex.EncodeToString(mac.Sum(nil))
+	sum := mac.Sum(nil)
+
+	actualMAC, err := EncodeSignature(sum, encoding)
+	if err != nil {
+		return "", err
+	}
 
 	for _, signature := range signatures {
-		if hmac.Equal([]byte(signature), []byte(actualMAC)) {
-			return actualMAC, err
+		sig, err := DecodeSignature(signature, encoding)
+		if err != nil {
+			continue
+		}
+
+		if hmac.Equal(sig, sum) {
+			return actualMAC, nil
 		}
 	}
 
@@ -205,7 +237,7 @@ func CheckPayloadSignature(payload []byte, secret, signature string) (string, er
 	signatures := ExtractSignatures(signature, "sha1=")
 
 	// Validate the MAC.
-	return ValidateMAC(payload, hmac.New(sha1.New, []byte(secret)), signatures)
+	return ValidateMAC(payload, hmac.New(sha1.New, []byte(secret)), signatures, "")
 }
 
 // CheckPayloadSignature256 calculates and verifies SHA256 signature of the given payload
@@ -218,7 +250,7 @@ func CheckPayloadSignature256(payload []byte, secret, signature string) (string,
 	signatures := ExtractSignatures(signature, "sha256=")
 
 	// Validate the MAC.
-	return ValidateMAC(payload, hmac.New(sha256.New, []byte(secret)), signatures)
+	return ValidateMAC(payload, hmac.New(sha256.New, []byte(secret)), signatures, "")
 }
 
 // CheckPayloadSignature512 calculates and verifies SHA512 signature of the given payload
@@ -231,7 +263,7 @@ func CheckPayloadSignature512(payload []byte, secret, signature string) (string,
 	signatures := ExtractSignatures(signature, "sha512=")
 
 	// Validate the MAC.
-	return ValidateMAC(payload, hmac.New(sha512.New, []byte(secret)), signatures)
+	return ValidateMAC(payload, hmac.New(sha512.New, []byte(secret)), signatures, "")
 }
 
 func CheckScalrSignature(r *Request, signingKey string, checkDate bool) (bool, error) {
@@ -281,39 +313,17 @@ func CheckScalrSignature(r *Request, signingKey string, checkDate bool) (bool, e
 // CheckIPWhitelist makes sure the provided remote address (of the form IP:port) falls within the provided IP range
 // (in CIDR form or a single IP address).
 func CheckIPWhitelist(remoteAddr, ipRange string) (bool, error) {
-	// Extract IP address from remote address.
-
-	// IPv6 addresses will likely be surrounded by [].
-	ip := strings.Trim(remoteAddr, " []")
-
-	if i := strings.LastIndex(ip, ":"); i != -1 {
-		ip = ip[:i]
-		ip = strings.Trim(ip, " []")
-	}
-
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
-		return false, fmt.Errorf("invalid IP address found in remote address '%s'", remoteAddr)
+	ip, err := parseRemoteIP(remoteAddr)
+	if err != nil {
+		return false, err
 	}
 
-	for _, r := range strings.Fields(ipRange) {
-		// Extract IP range in CIDR form.  If a single IP address is provided, turn it into CIDR form.
-
-		if !strings.Contains(r, "/") {
-			r = r + "/32"
-		}
-
-		_, cidr, err := net.ParseCIDR(r)
-		if err != nil {
-			return false, err
-		}
-
-		if cidr.Contains(parsedIP) {
-			return true, nil
-		}
+	ranges, err := ParseIPRanges(ipRange)
+	if err != nil {
+		return false, err
 	}
 
-	return false, nil
+	return ranges.Contains(ip), nil
 }
 
 // ReplaceParameter replaces parameter value with the passed value in the passed map
@@ -412,11 +422,37 @@ func GetParameter(s string, params interface{}) (interface{}, error) {
 // the passed string.  Complex data types are rendered as JSON instead of the Go
 // Stringer format.
 func ExtractParameterAsString(s string, params interface{}) (string, error) {
+	return ExtractParameterAsJoinedString(s, params, "")
+}
+
+// ExtractParameterAsJoinedString is like ExtractParameterAsString, but lists
+// are rendered as their elements joined with sep, unless sep is empty.
+func ExtractParameterAsJoinedString(s string, params interface{}, sep string) (string, error) {
 	pValue, err := GetParameter(s, params)
 	if err != nil {
 		return "", err
 	}
 
+	return parameterString(pValue, sep)
+}
+
+// parameterString renders a parameter value as a string.  Lists are joined
+// with sep, if not empty; other complex values are rendered as JSON.
+func parameterString(pValue interface{}, sep string) (string, error) {
+	if l, ok := pValue.([]interface{}); ok && sep != "" {
+		elems := make([]string, len(l))
+
+		for i := range l {
+			var err error
+
+			if elems[i], err = parameterString(l[i], ""); err != nil {
+				return "", err
+			}
+		}
+
+		return strings.Join(elems, sep), nil
+	}
+
 	switch v := reflect.ValueOf(pValue); v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice:
 		r, err := json.Marshal(pValue)
@@ -434,15 +470,38 @@ func ExtractParameterAsString(s string, params interface{}) (string, error) {
 // Argument type specifies the parameter key name and the source it should
 // be extracted from
 type Argument struct {
-	Source       string `json:"source,omitempty"`
-	Name         string `json:"name,omitempty"`
-	EnvName      string `json:"envname,omitempty"`
-	Base64Decode bool   `json:"base64decode,omitempty"`
+	Source       string      `json:"source,omitempty"`
+	Name         string      `json:"name,omitempty"`
+	EnvName      string      `json:"envname,omitempty"`
+	Base64Decode bool        `json:"base64decode,omitempty"`
+	Join         string      `json:"join,omitempty"`
+	Transform    []Transform `json:"transform,omitempty"`
+	Default      *string     `json:"default,omitempty"`
+
+	// template is the parsed Name of a template argument.
+	template *template.Template
 }
 
 // Get Argument method returns the value for the Argument's key name
-// based on the Argument's source
+// based on the Argument's source, passed through the Transform steps.  If
+// the value is missing and a Default is set, the Default is returned
+// instead.
 func (ha *Argument) Get(r *Request) (string, error) {
+	v, err := ha.get(r)
+
+	for i := 0; err == nil && i < len(ha.Transform); i++ {
+		v, err = ha.Transform[i].Apply(v)
+	}
+
+	if err != nil && ha.Default != nil && IsParameterNodeError(err) {
+		return *ha.Default, nil
+	}
+
+	return v, err
+}
+
+// get returns the untransformed value of the argument.
+func (ha *Argument) get(r *Request) (string, error) {
 	var source *map[string]interface{}
 	key := ha.Name
 
@@ -457,6 +516,20 @@ func (ha *Argument) Get(r *Request) (string, error) {
 	case SourcePayload:
 		source = &r.Payload
 
+	case SourceJWTClaim:
+		source = &r.JWTClaims
+
+	case SourcePath:
+		source = &r.PathParameters
+
+	case SourceClientCert:
+		if r == nil || r.RawRequest == nil {
+			return "", errors.New("request is nil")
+		}
+
+		values := ClientCertValues(r.RawRequest)
+		source = &values
+
 	case SourceString:
 		return ha.Name, nil
 
@@ -464,19 +537,23 @@ func (ha *Argument) Get(r *Request) (string, error) {
 		return string(r.Body), nil
 
 	case SourceRequest:
+		return r.RequestValue(ha.Name)
+
+	case SourceTemplate:
+		return r.renderTemplate(ha.template, ha.Name)
+
+	case SourceCookie:
 		if r == nil || r.RawRequest == nil {
 			return "", errors.New("request is nil")
 		}
 
-		switch strings.ToLower(ha.Name) {
-		case "remote-addr":
-			return r.RawRequest.RemoteAddr, nil
-		case "method":
-			return r.RawRequest.Method, nil
-		default:
-			return "", fmt.Errorf("unsupported request key: %q", ha.Name)
+		c, err := r.RawRequest.Cookie(ha.Name)
+		if err != nil {
+			return "", &ParameterNodeError{ha.Name}
 		}
 
+		return c.Value, nil
+
 	case SourceEntirePayload:
 		res, err := json.Marshal(&r.Payload)
 		if err != nil {
@@ -503,7 +580,20 @@ func (ha *Argument) Get(r *Request) (string, error) {
 	}
 
 	if source != nil {
-		return ExtractParameterAsString(key, *source)
+		// Repeated values are addressed as "name.N" or "name.*", unless the
+		// source has a field with that exact name.
+		if _, ok := (*source)[key]; !ok {
+			v, ok, err := lookupValues(r.values(ha.Source), key)
+			if err != nil {
+				return "", err
+			}
+
+			if ok {
+				return parameterString(v, ha.Join)
+			}
+		}
+
+		return ExtractParameterAsJoinedString(key, *source, ha.Join)
 	}
 
 	return "", errors.New("no source for value retrieval")
@@ -564,23 +654,37 @@ func (h *HooksFiles) Set(value string) error {
 
 // Hook type is a structure containing details for a single hook
 type Hook struct {
-	ID                                  string          `json:"id,omitempty"`
-	ExecuteCommand                      string          `json:"execute-command,omitempty"`
-	CommandWorkingDirectory             string          `json:"command-working-directory,omitempty"`
-	ResponseMessage                     string          `json:"response-message,omitempty"`
-	ResponseHeaders                     ResponseHeaders `json:"response-headers,omitempty"`
-	CaptureCommandOutput                bool            `json:"include-command-output-in-response,omitempty"`
-	CaptureCommandOutputOnError         bool            `json:"include-command-output-in-response-on-error,omitempty"`
-	PassEnvironmentToCommand            []Argument      `json:"pass-environment-to-command,omitempty"`
-	PassArgumentsToCommand              []Argument      `json:"pass-arguments-to-command,omitempty"`
-	PassFileToCommand                   []Argument      `json:"pass-file-to-command,omitempty"`
-	JSONStringParameters                []Argument      `json:"parse-parameters-as-json,omitempty"`
-	TriggerRule                         *Rules          `json:"trigger-rule,omitempty"`
-	TriggerRuleMismatchHttpResponseCode int             `json:"trigger-rule-mismatch-http-response-code,omitempty"`
-	TriggerSignatureSoftFailures        bool            `json:"trigger-signature-soft-failures,omitempty"`
-	IncomingPayloadContentType          string          `json:"incoming-payload-content-type,omitempty"`
-	SuccessHttpResponseCode             int             `json:"success-http-response-code,omitempty"`
-	HTTPMethods                         []string        `json:"http-methods"`
+	ID                                  string            `json:"id,omitempty"`
+	ExecuteCommand                      string            `json:"execute-command,omitempty"`
+	CommandWorkingDirectory             string            `json:"command-working-directory,omitempty"`
+	ResponseMessage                     string            `json:"response-message,omitempty"`
+	ResponseHeaders                     ResponseHeaders   `json:"response-headers,omitempty"`
+	CaptureCommandOutput                bool              `json:"include-command-output-in-response,omitempty"`
+	CaptureCommandOutputOnError         bool              `json:"include-command-output-in-response-on-error,omitempty"`
+	PassEnvironmentToCommand            []Argument        `json:"pass-environment-to-command,omitempty"`
+	PassArgumentsToCommand              []Argument        `json:"pass-arguments-to-command,omitempty"`
+	PassFileToCommand                   []Argument        `json:"pass-file-to-command,omitempty"`
+	JSONStringParameters                []Argument        `json:"parse-parameters-as-json,omitempty"`
+	TriggerRule                         *Rules            `json:"trigger-rule,omitempty"`
+	TriggerRuleMismatchHttpResponseCode int               `json:"trigger-rule-mismatch-http-response-code,omitempty"`
+	TriggerSignatureSoftFailures        bool              `json:"trigger-signature-soft-failures,omitempty"`
+	IncomingPayloadContentType          string            `json:"incoming-payload-content-type,omitempty"`
+	SuccessHttpResponseCode             int               `json:"success-http-response-code,omitempty"`
+	HTTPMethods                         []string          `json:"http-methods"`
+	RateLimit                           *RateLimit        `json:"rate-limit,omitempty"`
+	ReplayProtection                    *ReplayProtection `json:"replay-protection,omitempty"`
+	ExplainMismatch                     bool              `json:"explain-mismatch,omitempty"`
+	MaxBodySize                         int64             `json:"max-body-size,omitempty"`
+	DecompressBody                      bool              `json:"decompress-body,omitempty"`
+	MaxDecompressedBodySize             int64             `json:"max-decompressed-body-size,omitempty"`
+	Path                                string            `json:"path,omitempty"`
+	ResponseMessageTemplate             bool              `json:"response-message-template,omitempty"`
+
+	// pathPattern is the parsed Path.
+	pathPattern []pathSegment
+
+	// responseMessage is the parsed ResponseMessage if it is a template.
+	responseMessage *template.Template
 }
 
 // ParseJSONParameters decodes specified arguments to JSON objects and replaces the
@@ -757,13 +861,7 @@ func (h *Hooks) LoadFromFile(path string, asTemplate bool) error {
 	}
 
 	if asTemplate {
-		funcMap := template.FuncMap{
-			"cat":        cat,
-			"credential": credential,
-			"getenv":     getenv,
-		}
-
-		tmpl, err := template.New("hooks").Funcs(funcMap).Parse(string(file))
+		tmpl, err := template.New("hooks").Funcs(templateFuncs).Parse(string(file))
 		if err != nil {
 			return err
 		}
@@ -778,7 +876,11 @@ func (h *Hooks) LoadFromFile(path string, asTemplate bool) error {
 		file = buf.Bytes()
 	}
 
-	return yaml.Unmarshal(file, h)
+	if err := yaml.Unmarshal(file, h); err != nil {
+		return err
+	}
+
+	return h.compile()
 }
 
 // Append appends hooks unless the new hooks contain a hook with an ID that already exists
@@ -812,11 +914,20 @@ type Rules struct {
 	Or    *OrRule    `json:"or,omitempty"`
 	Not   *NotRule   `json:"not,omitempty"`
 	Match *MatchRule `json:"match,omitempty"`
+	Expr  *ExprRule  `json:"expr,omitempty"`
 }
 
 // Evaluate finds the first rule property that is not nil and returns the value
 // it evaluates to
 func (r Rules) Evaluate(req *Request) (bool, error) {
+	t := req.beginTrace(r)
+	ok, err := r.evaluate(req)
+	req.endTrace(t, ok, err)
+
+	return ok, err
+}
+
+func (r Rules) evaluate(req *Request) (bool, error) {
 	switch {
 	case r.And != nil:
 		return r.And.Evaluate(req)
@@ -826,6 +937,8 @@ func (r Rules) Evaluate(req *Request) (bool, error) {
 		return r.Not.Evaluate(req)
 	case r.Match != nil:
 		return r.Match.Evaluate(req)
+	case r.Expr != nil:
+		return r.Expr.Evaluate(req)
 	}
 
 	return false, nil
@@ -844,12 +957,16 @@ func (r AndRule) Evaluate(req *Request) (bool, error) {
 			return false, err
 		}
 
+		// The authentication challenge of the failed rule, if any, is
+		// kept.
 		res = res && rv
 		if !res {
 			return res, nil
 		}
 	}
 
+	req.AuthChallenge = ""
+
 	return res, nil
 }
 
@@ -860,6 +977,9 @@ type OrRule []Rules
 func (r OrRule) Evaluate(req *Request) (bool, error) {
 	res := false
 
+	// If all rules fail, the first authentication challenge is kept.
+	challenge := ""
+
 	for _, v := range r {
 		rv, err := v.Evaluate(req)
 		if err != nil {
@@ -872,10 +992,17 @@ func (r OrRule) Evaluate(req *Request) (bool, error) {
 
 		res = res || rv
 		if res {
+			req.AuthChallenge = ""
 			return res, nil
 		}
+
+		if challenge == "" {
+			challenge = req.AuthChallenge
+		}
 	}
 
+	req.AuthChallenge = challenge
+
 	return res, nil
 }
 
@@ -885,48 +1012,141 @@ type NotRule Rules
 // Evaluate NotRule will return true if and only if ChildRule evaluates to false
 func (r NotRule) Evaluate(req *Request) (bool, error) {
 	rv, err := Rules(r).Evaluate(req)
+
+	// A failed authentication makes the negated rule succeed, so it must
+	// not be challenged.
+	req.AuthChallenge = ""
+
 	return !rv, err
 }
 
 // MatchRule will evaluate to true based on the type
 type MatchRule struct {
-	Type      string   `json:"type,omitempty"`
-	Regex     string   `json:"regex,omitempty"`
-	Secret    string   `json:"secret,omitempty"`
-	Value     string   `json:"value,omitempty"`
-	Parameter Argument `json:"parameter,omitempty"`
-	IPRange   string   `json:"ip-range,omitempty"`
+	Type            string             `json:"type,omitempty"`
+	Regex           string             `json:"regex,omitempty"`
+	Secret          string             `json:"secret,omitempty"`
+	Value           string             `json:"value,omitempty"`
+	Parameter       Argument           `json:"parameter,omitempty"`
+	IPRange         string             `json:"ip-range,omitempty"`
+	TimeWindow      *TimeWindow        `json:"time-window,omitempty"`
+	JWT             *JWTOptions        `json:"jwt,omitempty"`
+	PublicKey       string             `json:"public-key,omitempty"`
+	PublicKeyFile   string             `json:"public-key-file,omitempty"`
+	Timestamp       *Argument          `json:"timestamp,omitempty"`
+	Encoding        string             `json:"encoding,omitempty"`
+	Algorithm       string             `json:"algorithm,omitempty"`
+	Prefix          string             `json:"prefix,omitempty"`
+	SignedContent   string             `json:"signed-content,omitempty"`
+	Tolerance       string             `json:"tolerance,omitempty"`
+	KeyDir          string             `json:"key-dir,omitempty"`
+	Components      []string           `json:"components,omitempty"`
+	Credentials     []string           `json:"credentials,omitempty"`
+	CredentialsFile string             `json:"credentials-file,omitempty"`
+	Realm           string             `json:"realm,omitempty"`
+	ClientCert      *ClientCertOptions `json:"client-cert,omitempty"`
+	IPListFile      string             `json:"ip-list-file,omitempty"`
+	DenyIPRange     string             `json:"deny-ip-range,omitempty"`
+	DenyIPListFile  string             `json:"deny-ip-list-file,omitempty"`
+	SecretFile      string             `json:"secret-file,omitempty"`
+	Secrets         []Secret           `json:"secrets,omitempty"`
+	SignedBody      string             `json:"signed-body,omitempty"`
+
+	// regex is the compiled Regex.
+	regex *regexp.Regexp
+
+	// signedContentTemplate is the parsed SignedContent.
+	signedContentTemplate *template.Template
+
+	// ipRanges and denyIPRanges are the parsed IPRange and DenyIPRange.
+	ipRanges         IPRanges
+	denyIPRanges     IPRanges
+	ipRangesCompiled bool
 }
 
 // Constants for the MatchRule type
 const (
-	MatchValue      string = "value"
-	MatchRegex      string = "regex"
-	MatchHMACSHA1   string = "payload-hmac-sha1"
-	MatchHMACSHA256 string = "payload-hmac-sha256"
-	MatchHMACSHA512 string = "payload-hmac-sha512"
-	MatchHashSHA1   string = "payload-hash-sha1"
-	MatchHashSHA256 string = "payload-hash-sha256"
-	MatchHashSHA512 string = "payload-hash-sha512"
-	IPWhitelist     string = "ip-whitelist"
-	ScalrSignature  string = "scalr-signature"
+	MatchValue           string = "value"
+	MatchRegex           string = "regex"
+	MatchHMACSHA1        string = "payload-hmac-sha1"
+	MatchHMACSHA256      string = "payload-hmac-sha256"
+	MatchHMACSHA512      string = "payload-hmac-sha512"
+	MatchHashSHA1        string = "payload-hash-sha1"
+	MatchHashSHA256      string = "payload-hash-sha256"
+	MatchHashSHA512      string = "payload-hash-sha512"
+	IPWhitelist          string = "ip-whitelist"
+	ScalrSignature       string = "scalr-signature"
+	TimeWindowRule       string = "time-window"
+	MatchJWT             string = "jwt"
+	MatchEd25519         string = "payload-ed25519"
+	MatchRSASHA256       string = "payload-rsa-sha256"
+	MatchECDSASHA256     string = "payload-ecdsa-sha256"
+	MatchHMACTimestamped string = "payload-hmac-timestamped"
+	MatchHMAC            string = "payload-hmac"
+	StandardWebhooks     string = "standard-webhooks"
+	HTTPMessageSignature string = "http-message-signature"
+	MatchBasicAuth       string = "basic-auth"
+	MatchBearerToken     string = "bearer-token"
+	MatchClientCert      string = "client-cert"
 )
 
 // Evaluate MatchRule will return based on the type
 func (r MatchRule) Evaluate(req *Request) (bool, error) {
+	req.AuthChallenge = ""
+
+	if r.SignedBody == SignedBodyCompressed && req.CompressedBody != nil {
+		body := req.Body
+		req.Body = req.CompressedBody
+		defer func() { req.Body = body }()
+	}
+	if r.usesSecret() && (r.SecretFile != "" || len(r.Secrets) != 0) {
+		return r.evaluateWithSecrets(req)
+	}
 	if r.Type == IPWhitelist {
-		return CheckIPWhitelist(req.RawRequest.RemoteAddr, r.IPRange)
+		return r.checkIPWhitelist(req)
 	}
 	if r.Type == ScalrSignature {
 		return CheckScalrSignature(req, r.Secret, true)
 	}
+	if r.Type == TimeWindowRule {
+		return CheckTimeWindow(req.now(), r.TimeWindow)
+	}
+	if r.Type == StandardWebhooks {
+		tolerance, err := r.tolerance()
+		if err != nil {
+			return false, err
+		}
+
+		return CheckStandardWebhooksSignature(req, r.Secret, tolerance, req.now())
+	}
+	if r.Type == HTTPMessageSignature {
+		tolerance, err := r.tolerance()
+		if err != nil {
+			return false, err
+		}
+
+		return CheckHTTPMessageSignature(req, r.KeyDir, r.httpSigComponents(), tolerance, req.now())
+	}
+	if r.Type == MatchBasicAuth {
+		return r.checkBasicAuth(req)
+	}
+	if r.Type == MatchBearerToken {
+		return r.checkBearerToken(req)
+	}
+	if r.Type == MatchClientCert {
+		return CheckClientCert(req.RawRequest, r.ClientCert)
+	}
 
 	arg, err := r.Parameter.Get(req)
 	if err == nil {
+		req.traceValue(r, arg)
+
 		switch r.Type {
 		case MatchValue:
 			return compare(arg, r.Value), nil
 		case MatchRegex:
+			if r.regex != nil {
+				return r.regex.MatchString(arg), nil
+			}
 			return regexp.MatchString(r.Regex, arg)
 		case MatchHashSHA1:
 			log.Print(`warn: use of deprecated option payload-hash-sha1; use payload-hmac-sha1 instead`)
@@ -946,6 +1166,20 @@ func (r MatchRule) Evaluate(req *Request) (bool, error) {
 		case MatchHMACSHA512:
 			_, err := CheckPayloadSignature512(req.Body, r.Secret, arg)
 			return err == nil, err
+		case MatchEd25519, MatchRSASHA256, MatchECDSASHA256:
+			return r.checkPublicKeySignature(req, arg)
+		case MatchHMACTimestamped:
+			return r.checkTimestampedHMAC(req, arg)
+		case MatchHMAC:
+			return r.checkHMAC(req, arg)
+		case MatchJWT:
+			claims, err := CheckJWT(arg, r.Secret, r.JWT, req.now())
+			if err != nil {
+				return false, err
+			}
+
+			req.JWTClaims = claims
+			return true, nil
 		}
 	}
 	return false, err
@@ -2,6 +2,7 @@ package hook
 
 import (
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
@@ -205,6 +206,11 @@ var checkIPWhitelistTests = []struct {
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/48 ", true, true},
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/48 2001:db8:1::/64", true, true},
 	{" [2001:db8:1:2::1:1234] ", "  2001:db8:1::/64 ", false, true},
+	{"[2001:db8::1]:1234", "2001:db8::1", true, true},
+	{"[2001:db8::2]:1234", "2001:db8::1", false, true},
+	{"[2001:db8::2]:1234", "10.0.0.1, 2001:db8::2", true, true},
+	{"[::ffff:10.0.0.1]:1234", "10.0.0.0/8", true, true},
+	{"10.0.0.1:1234", "10.0.0.1/33", false, false},
 }
 
 func TestCheckIPWhitelist(t *testing.T) {
@@ -274,7 +280,7 @@ var argumentGetTests = []struct {
 
 func TestArgumentGet(t *testing.T) {
 	for _, tt := range argumentGetTests {
-		a := Argument{tt.source, tt.name, "", false}
+		a := Argument{Source: tt.source, Name: tt.name}
 		r := &Request{
 			Headers:    tt.headers,
 			Query:      tt.query,
@@ -288,20 +294,105 @@ func TestArgumentGet(t *testing.T) {
 	}
 }
 
+func TestArgumentGetRequest(t *testing.T) {
+	raw := httptest.NewRequest("POST", "https://example.com/hooks/deploy?ref=main&x=1", strings.NewReader(`{}`))
+	raw.Header.Set("Content-Type", "application/json")
+	raw.Header.Set("User-Agent", "GitHub-Hookshot/abc")
+	raw.AddCookie(&http.Cookie{Name: "session", Value: "s3cr3t"})
+
+	r := &Request{ID: "rid", HookID: "deploy", RawRequest: raw}
+
+	plain := httptest.NewRequest("GET", "/hooks/deploy", nil)
+	plain.Header.Del("User-Agent")
+
+	for _, tt := range []struct {
+		source, name string
+		request      *Request
+		value        string
+		ok           bool
+	}{
+		{"request", "host", r, "example.com", true},
+		{"request", "path", r, "/hooks/deploy", true},
+		{"request", "raw-query", r, "ref=main&x=1", true},
+		{"request", "scheme", r, "https", true},
+		{"request", "proto", r, "HTTP/1.1", true},
+		{"request", "content-length", r, "2", true},
+		{"request", "content-type", r, "application/json", true},
+		{"request", "User-Agent", r, "GitHub-Hookshot/abc", true},
+		{"request", "tls-version", r, "TLS 1.2", true},
+		{"request", "request-id", r, "rid", true},
+		{"request", "hook-id", r, "deploy", true},
+		{"request", "scheme", &Request{RawRequest: plain}, "http", true},
+		{"request", "tls-version", &Request{RawRequest: plain}, "", true},
+		{"request", "user-agent", &Request{RawRequest: plain}, "", true},
+		{"cookie", "session", r, "s3cr3t", true},
+		// failures
+		{"request", "cookie", r, "", false},
+		{"cookie", "missing", r, "", false},
+		{"cookie", "session", &Request{}, "", false},
+	} {
+		a := Argument{Source: tt.source, Name: tt.name}
+
+		value, err := a.Get(tt.request)
+		if (err == nil) != tt.ok || value != tt.value {
+			t.Errorf("failed to get {%q, %q}:\nexpected {value:%#v, ok:%#v},\ngot {value:%#v, err:%v}", tt.source, tt.name, tt.value, tt.ok, value, err)
+		}
+	}
+}
+
+func TestArgumentGetMultipleValues(t *testing.T) {
+	r := &Request{Body: []byte("tag=a&tag=b&tag=c&single=x&list.1=raw")}
+	r.ParseHeaders(map[string][]string{"X-Tag": {"h1", "h2"}})
+	r.ParseQuery(map[string][]string{"tag": {"q1", "q2"}})
+
+	if err := r.ParseFormPayload(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		source, name, join string
+		value              string
+		ok                 bool
+	}{
+		{"header", "x-tag", "", "h1", true},
+		{"header", "x-tag.1", "", "h2", true},
+		{"header", "X-Tag.*", "", `["h1","h2"]`, true},
+		{"url", "tag", "", "q1", true},
+		{"url", "tag.0", "", "q1", true},
+		{"url", "tag.*", " ", "q1 q2", true},
+		{"payload", "tag", "", "a", true},
+		{"payload", "tag.2", "", "c", true},
+		{"payload", "tag.*", ",", "a,b,c", true},
+		{"payload", "single.*", "", `["x"]`, true},
+		{"payload", "list.1", "", "raw", true},
+		// failures
+		{"url", "tag.2", "", "", false},
+		{"payload", "tag.x", "", "", false},
+		{"payload", "missing.*", "", "", false},
+	} {
+		a := Argument{Source: tt.source, Name: tt.name, Join: tt.join}
+
+		value, err := a.Get(r)
+		if (err == nil) != tt.ok || value != tt.value {
+			t.Errorf("failed to get {%q, %q}:\nexpected {value:%#v, ok:%#v},\ngot {value:%#v, err:%v}", tt.source, tt.name, tt.value, tt.ok, value, err)
+		}
+	}
+}
+
 var hookParseJSONParametersTests = []struct {
 	params                     []Argument
 	headers, query, payload    map[string]interface{}
 	rheaders, rquery, rpayload map[string]interface{}
 	ok                         bool
 }{
-	{[]Argument{Argument{"header", "a", "", false}}, map[string]interface{}{"A": `{"b": "y"}`}, nil, nil, map[string]interface{}{"A": map[string]interface{}{"b": "y"}}, nil, nil, true},
-	{[]Argument{Argument{"url", "a", "", false}}, nil, map[string]interface{}{"a": `{"b": "y"}`}, nil, nil, map[string]interface{}{"a": map[string]interface{}{"b": "y"}}, nil, true},
-	{[]Argument{Argument{"payload", "a", "", false}}, nil, nil, map[string]interface{}{"a": `{"b": "y"}`}, nil, nil, map[string]interface{}{"a": map[string]interface{}{"b": "y"}}, true},
-	{[]Argument{Argument{"header", "z", "", false}}, map[string]interface{}{"Z": `{}`}, nil, nil, map[string]interface{}{"Z": map[string]interface{}{}}, nil, nil, true},
+	{[]Argument{Argument{Source: "header", Name: "a"}}, map[string]interface{}{"A": `{"b": "y"}`}, nil, nil, map[string]interface{}{"A": map[string]interface{}{"b": "y"}}, nil, nil, true},
+	{[]Argument{Argument{Source: "url", Name: "a"}}, nil, map[string]interface{}{"a": `{"b": "y"}`}, nil, nil, map[string]interface{}{"a": map[string]interface{}{"b": "y"}}, nil, true},
+	{[]Argument{Argument{Source: "payload", Name: "a"}}, nil, nil, map[string]interface{}{"a": `{"b": "y"}`}, nil, nil, map[string]interface{}{"a": map[string]interface{}{"b": "y"}}, true},
+	{[]Argument{Argument{Source: "header", Name: "z"}}, map[string]interface{}{"Z": `{}`}, nil, nil, map[string]interface{}{"Z": map[string]interface{}{}}, nil, nil, true},
 	// failures
-	{[]Argument{Argument{"header", "z", "", false}}, map[string]interface{}{"Z": ``}, nil, nil, map[string]interface{}{"Z": ``}, nil, nil, false},     // empty string
-	{[]Argument{Argument{"header", "y", "", false}}, map[string]interface{}{"X": `{}`}, nil, nil, map[string]interface{}{"X": `{}`}, nil, nil, false}, // missing parameter
-	{[]Argument{Argument{"string", "z", "", false}}, map[string]interface{}{"Z": ``}, nil, nil, map[string]interface{}{"Z": ``}, nil, nil, false},     // invalid argument source
+	{[]Argument{Argument{Source: "header", Name: "z"}}, map[string]interface{}{"Z": ``}, nil, nil, map[string]interface{}{"Z": ``}, nil, nil, false},     // empty string
+	{[]Argument{Argument{Source: "header", Name: "y"}}, map[string]interface{}{"X": `{}`}, nil, nil, map[string]interface{}{"X": `{}`}, nil, nil, false}, // missing parameter
+	{[]Argument{Argument{Source: "string", Name: "z"}}, map[string]interface{}{"Z": ``}, nil, nil, map[string]interface{}{"Z": ``}, nil, nil, false},     // invalid argument source
 }
 
 func TestHookParseJSONParameters(t *testing.T) {
@@ -326,9 +417,9 @@ var hookExtractCommandArgumentsTests = []struct {
 	value                   []string
 	ok                      bool
 }{
-	{"test", []Argument{Argument{"header", "a", "", false}}, map[string]interface{}{"A": "z"}, nil, nil, []string{"test", "z"}, true},
+	{"test", []Argument{Argument{Source: "header", Name: "a"}}, map[string]interface{}{"A": "z"}, nil, nil, []string{"test", "z"}, true},
 	// failures
-	{"fail", []Argument{Argument{"payload", "a", "", false}}, map[string]interface{}{"A": "z"}, nil, nil, []string{"fail", ""}, false},
+	{"fail", []Argument{Argument{Source: "payload", Name: "a"}}, map[string]interface{}{"A": "z"}, nil, nil, []string{"fail", ""}, false},
 }
 
 func TestHookExtractCommandArguments(t *testing.T) {
@@ -375,14 +466,14 @@ var hookExtractCommandArgumentsForEnvTests = []struct {
 	// successes
 	{
 		"test",
-		[]Argument{Argument{"header", "a", "", false}},
+		[]Argument{Argument{Source: "header", Name: "a"}},
 		map[string]interface{}{"A": "z"}, nil, nil,
 		[]string{"HOOK_a=z"},
 		true,
 	},
 	{
 		"test",
-		[]Argument{Argument{"header", "a", "MYKEY", false}},
+		[]Argument{Argument{Source: "header", Name: "a", EnvName: "MYKEY"}},
 		map[string]interface{}{"A": "z"}, nil, nil,
 		[]string{"MYKEY=z"},
 		true,
@@ -390,7 +481,7 @@ var hookExtractCommandArgumentsForEnvTests = []struct {
 	// failures
 	{
 		"fail",
-		[]Argument{Argument{"payload", "a", "", false}},
+		[]Argument{Argument{Source: "payload", Name: "a"}},
 		map[string]interface{}{"A": "z"}, nil, nil,
 		[]string{},
 		false,
@@ -489,24 +580,24 @@ var matchRuleTests = []struct {
 	ok                                 bool
 	err                                bool
 }{
-	{"value", "", "", "z", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", true, false},
-	{"regex", "^z", "", "z", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", true, false},
-	{"payload-hmac-sha1", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "b17e04cbb22afa8ffbff8796fc1894ed27badd9e"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
-	{"payload-hash-sha1", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "b17e04cbb22afa8ffbff8796fc1894ed27badd9e"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
-	{"payload-hmac-sha256", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "f417af3a21bd70379b5796d5f013915e7029f62c580fb0f500f59a35a6f04c89"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
-	{"payload-hash-sha256", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "f417af3a21bd70379b5796d5f013915e7029f62c580fb0f500f59a35a6f04c89"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
+	{"value", "", "", "z", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", true, false},
+	{"regex", "^z", "", "z", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", true, false},
+	{"payload-hmac-sha1", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "b17e04cbb22afa8ffbff8796fc1894ed27badd9e"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
+	{"payload-hash-sha1", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "b17e04cbb22afa8ffbff8796fc1894ed27badd9e"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
+	{"payload-hmac-sha256", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "f417af3a21bd70379b5796d5f013915e7029f62c580fb0f500f59a35a6f04c89"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
+	{"payload-hash-sha256", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "f417af3a21bd70379b5796d5f013915e7029f62c580fb0f500f59a35a6f04c89"}, nil, nil, []byte(`{"a": "z"}`), "", true, false},
 	// failures
-	{"value", "", "", "X", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, false},
-	{"regex", "^X", "", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, false},
-	{"value", "", "2", "X", "", Argument{"header", "a", "", false}, map[string]interface{}{"Y": "z"}, nil, nil, []byte{}, "", false, true}, // reference invalid header
+	{"value", "", "", "X", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, false},
+	{"regex", "^X", "", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, false},
+	{"value", "", "2", "X", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"Y": "z"}, nil, nil, []byte{}, "", false, true}, // reference invalid header
 	// errors
-	{"regex", "*", "", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, true},                   // invalid regex
-	{"payload-hmac-sha1", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true},   // invalid hmac
-	{"payload-hash-sha1", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true},   // invalid hmac
-	{"payload-hmac-sha256", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
-	{"payload-hash-sha256", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
-	{"payload-hmac-sha512", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
-	{"payload-hash-sha512", "", "secret", "", "", Argument{"header", "a", "", false}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
+	{"regex", "*", "", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, "", false, true},                   // invalid regex
+	{"payload-hmac-sha1", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true},   // invalid hmac
+	{"payload-hash-sha1", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true},   // invalid hmac
+	{"payload-hmac-sha256", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
+	{"payload-hash-sha256", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
+	{"payload-hmac-sha512", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
+	{"payload-hash-sha512", "", "secret", "", "", Argument{Source: "header", Name: "a"}, map[string]interface{}{"A": ""}, nil, nil, []byte{}, "", false, true}, // invalid hmac
 	// IP whitelisting, valid cases
 	{"ip-whitelist", "", "", "", "192.168.0.1/24", Argument{}, nil, nil, nil, []byte{}, "192.168.0.2:9000", true, false}, // valid IPv4, with range
 	{"ip-whitelist", "", "", "", "192.168.0.1/24", Argument{}, nil, nil, nil, []byte{}, "192.168.0.2:9000", true, false}, // valid IPv4, with range
@@ -524,7 +615,7 @@ var matchRuleTests = []struct {
 
 func TestMatchRule(t *testing.T) {
 	for i, tt := range matchRuleTests {
-		r := MatchRule{tt.typ, tt.regex, tt.secret, tt.value, tt.param, tt.ipRange}
+		r := MatchRule{Type: tt.typ, Regex: tt.regex, Secret: tt.secret, Value: tt.value, Parameter: tt.param, IPRange: tt.ipRange}
 		req := &Request{
 			Headers: tt.headers,
 			Query:   tt.query,
@@ -552,8 +643,8 @@ var andRuleTests = []struct {
 	{
 		"(a=z, b=y): a=z && b=y",
 		AndRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
-			{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
+			{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
 		},
 		map[string]interface{}{"A": "z", "B": "y"}, nil, nil,
 		[]byte{},
@@ -562,8 +653,8 @@ var andRuleTests = []struct {
 	{
 		"(a=z, b=Y): a=z && b=y",
 		AndRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
-			{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
+			{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
 		},
 		map[string]interface{}{"A": "z", "B": "Y"}, nil, nil,
 		[]byte{},
@@ -573,22 +664,22 @@ var andRuleTests = []struct {
 	{
 		"(a=z, b=y, c=x, d=w=, e=X, f=X): a=z && (b=y && c=x) && (d=w || e=v) && !f=u",
 		AndRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
 			{
 				And: &AndRule{
-					{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
-					{Match: &MatchRule{"value", "", "", "x", Argument{"header", "c", "", false}, ""}},
+					{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
+					{Match: &MatchRule{Type: "value", Value: "x", Parameter: Argument{Source: "header", Name: "c"}}},
 				},
 			},
 			{
 				Or: &OrRule{
-					{Match: &MatchRule{"value", "", "", "w", Argument{"header", "d", "", false}, ""}},
-					{Match: &MatchRule{"value", "", "", "v", Argument{"header", "e", "", false}, ""}},
+					{Match: &MatchRule{Type: "value", Value: "w", Parameter: Argument{Source: "header", Name: "d"}}},
+					{Match: &MatchRule{Type: "value", Value: "v", Parameter: Argument{Source: "header", Name: "e"}}},
 				},
 			},
 			{
 				Not: &NotRule{
-					Match: &MatchRule{"value", "", "", "u", Argument{"header", "f", "", false}, ""},
+					Match: &MatchRule{Type: "value", Value: "u", Parameter: Argument{Source: "header", Name: "f"}},
 				},
 			},
 		},
@@ -600,7 +691,7 @@ var andRuleTests = []struct {
 	// failures
 	{
 		"invalid rule",
-		AndRule{{Match: &MatchRule{"value", "", "", "X", Argument{"header", "a", "", false}, ""}}},
+		AndRule{{Match: &MatchRule{Type: "value", Value: "X", Parameter: Argument{Source: "header", Name: "a"}}}},
 		map[string]interface{}{"Y": "z"}, nil, nil, nil,
 		false, true,
 	},
@@ -632,8 +723,8 @@ var orRuleTests = []struct {
 	{
 		"(a=z, b=X): a=z || b=y",
 		OrRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
-			{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
+			{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
 		},
 		map[string]interface{}{"A": "z", "B": "X"}, nil, nil,
 		[]byte{},
@@ -642,8 +733,8 @@ var orRuleTests = []struct {
 	{
 		"(a=X, b=y): a=z || b=y",
 		OrRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
-			{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
+			{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
 		},
 		map[string]interface{}{"A": "X", "B": "y"}, nil, nil,
 		[]byte{},
@@ -652,8 +743,8 @@ var orRuleTests = []struct {
 	{
 		"(a=Z, b=Y): a=z || b=y",
 		OrRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
-			{Match: &MatchRule{"value", "", "", "y", Argument{"header", "b", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
+			{Match: &MatchRule{Type: "value", Value: "y", Parameter: Argument{Source: "header", Name: "b"}}},
 		},
 		map[string]interface{}{"A": "Z", "B": "Y"}, nil, nil,
 		[]byte{},
@@ -663,7 +754,7 @@ var orRuleTests = []struct {
 	{
 		"missing parameter node",
 		OrRule{
-			{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}},
+			{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}},
 		},
 		map[string]interface{}{"Y": "Z"}, nil, nil,
 		[]byte{},
@@ -694,8 +785,8 @@ var notRuleTests = []struct {
 	ok                      bool
 	err                     bool
 }{
-	{"(a=z): !a=X", NotRule{Match: &MatchRule{"value", "", "", "X", Argument{"header", "a", "", false}, ""}}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, true, false},
-	{"(a=z): !a=z", NotRule{Match: &MatchRule{"value", "", "", "z", Argument{"header", "a", "", false}, ""}}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, false, false},
+	{"(a=z): !a=X", NotRule{Match: &MatchRule{Type: "value", Value: "X", Parameter: Argument{Source: "header", Name: "a"}}}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, true, false},
+	{"(a=z): !a=z", NotRule{Match: &MatchRule{Type: "value", Value: "z", Parameter: Argument{Source: "header", Name: "a"}}}, map[string]interface{}{"A": "z"}, nil, nil, []byte{}, false, false},
 }
 
 func TestNotRule(t *testing.T) {
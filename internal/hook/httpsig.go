@@ -0,0 +1,420 @@
+package hook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Constants for the HTTP message signature algorithms defined by RFC 9421
+const (
+	HTTPSigRSAPSSSHA512    string = "rsa-pss-sha512"
+	HTTPSigRSAV15SHA256    string = "rsa-v1_5-sha256"
+	HTTPSigHMACSHA256      string = "hmac-sha256"
+	HTTPSigECDSAP256SHA256 string = "ecdsa-p256-sha256"
+	HTTPSigECDSAP384SHA384 string = "ecdsa-p384-sha384"
+	HTTPSigEd25519         string = "ed25519"
+)
+
+// httpSigKeyFiles caches the keys parsed from files in key directories.  A
+// file contains either PEM encoded public keys or a base64 encoded shared
+// secret for hmac-sha256.
+var httpSigKeyFiles = newFileCache(func(data []byte) (interface{}, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		keys, err := ParsePublicKeysPEM(data)
+		if err != nil {
+			return nil, err
+		}
+
+		v := make([]interface{}, len(keys))
+		for i := range keys {
+			v[i] = keys[i]
+		}
+
+		return v, nil
+	}
+
+	secret, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid shared secret: %w", err)
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.New("empty shared secret")
+	}
+
+	return []interface{}{secret}, nil
+})
+
+// httpSigKeyFileSuffixes are the file name suffixes tried when looking up a
+// key by its keyid.
+var httpSigKeyFileSuffixes = []string{"", ".pem", ".key"}
+
+// lookupHTTPSigKeys returns the keys stored for keyid in dir.  A nil slice is
+// returned if there is no such key.
+func lookupHTTPSigKeys(dir, keyid string) ([]interface{}, error) {
+	if keyid == "" || keyid != filepath.Base(keyid) || strings.HasPrefix(keyid, ".") || strings.ContainsAny(keyid, `/\`) {
+		return nil, nil
+	}
+
+	for _, suffix := range httpSigKeyFileSuffixes {
+		v, err := httpSigKeyFiles.Get(filepath.Join(dir, keyid+suffix))
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, fmt.Errorf("error loading key %q: %w", keyid, err)
+		}
+
+		return v.([]interface{}), nil
+	}
+
+	return nil, nil
+}
+
+// DefaultHTTPMessageSignatureComponents are the components a signature must
+// cover if the http-message-signature rule lists none, so that it is bound
+// to the request method, target and body.
+var DefaultHTTPMessageSignatureComponents = []string{"@method", "@target-uri", "content-digest"}
+
+// httpSigComponents returns the components a signature must cover.
+func (r *MatchRule) httpSigComponents() []string {
+	if len(r.Components) == 0 {
+		return DefaultHTTPMessageSignatureComponents
+	}
+
+	return r.Components
+}
+
+// CheckHTTPMessageSignature verifies the Signature-Input and Signature
+// headers of the request as defined by RFC 9421.  Keys are looked up by the
+// keyid parameter in keyDir.  A signature is accepted only if it covers all
+// of the required components, its created parameter is within tolerance of
+// now and it has not expired.  If content-digest is covered, the digest is
+// also checked against the body.
+func CheckHTTPMessageSignature(r *Request, keyDir string, required []string, tolerance time.Duration, now time.Time) (bool, error) {
+	if keyDir == "" {
+		return false, errors.New("http message signature validation key-dir can not be empty")
+	}
+
+	if r.RawRequest == nil {
+		return false, errors.New("request is nil")
+	}
+
+	inputHeader := strings.Join(r.RawRequest.Header.Values("Signature-Input"), ", ")
+	signatureHeader := strings.Join(r.RawRequest.Header.Values("Signature"), ", ")
+
+	if inputHeader == "" || signatureHeader == "" {
+		return false, nil
+	}
+
+	inputs, err := parseSFDictionary(inputHeader)
+	if err != nil {
+		return false, &SignatureError{Signature: "malformed Signature-Input"}
+	}
+
+	signatures, err := parseSFDictionary(signatureHeader)
+	if err != nil {
+		return false, &SignatureError{Signature: "malformed Signature"}
+	}
+
+	var lastErr error = &SignatureError{Signature: signatureHeader}
+
+	for _, input := range inputs {
+		var sig []byte
+
+		for _, s := range signatures {
+			if s.Name == input.Name && s.Item != nil {
+				sig, _ = s.Item.Value.([]byte)
+			}
+		}
+
+		if sig == nil || input.Items == nil {
+			continue
+		}
+
+		err := verifyHTTPMessageSignature(r, input, sig, keyDir, required, tolerance, now)
+		if err == nil {
+			return true, nil
+		}
+
+		if !IsSignatureError(err) {
+			return false, err
+		}
+
+		lastErr = err
+	}
+
+	return false, lastErr
+}
+
+// verifyHTTPMessageSignature verifies a single signature.
+func verifyHTTPMessageSignature(r *Request, input sfMember, sig []byte, keyDir string, required []string, tolerance time.Duration, now time.Time) error {
+	covered := make([]string, 0, len(input.Items))
+
+	for _, item := range input.Items {
+		name, ok := item.Value.(string)
+		if !ok || len(item.Params) != 0 {
+			return &SignatureError{Signature: fmt.Sprintf("unsupported component in signature %s", input.Name)}
+		}
+
+		if containsString(covered, name) {
+			return &SignatureError{Signature: fmt.Sprintf("duplicate component %q in signature %s", name, input.Name)}
+		}
+
+		covered = append(covered, name)
+	}
+
+	for _, name := range required {
+		if !containsString(covered, strings.ToLower(name)) {
+			return &SignatureError{Signature: fmt.Sprintf("signature %s does not cover %q", input.Name, name)}
+		}
+	}
+
+	created, ok := input.Params.Get("created")
+	if !ok {
+		return &SignatureError{Signature: fmt.Sprintf("signature %s has no created parameter", input.Name)}
+	}
+
+	if c, ok := created.(int64); !ok {
+		return &SignatureError{Signature: "invalid created parameter"}
+	} else if delta := now.Sub(time.Unix(c, 0)); delta > tolerance || delta < -tolerance {
+		return &SignatureError{Signature: "outdated"}
+	}
+
+	if expires, ok := input.Params.Get("expires"); ok {
+		if e, ok := expires.(int64); !ok {
+			return &SignatureError{Signature: "invalid expires parameter"}
+		} else if !now.Before(time.Unix(e, 0)) {
+			return &SignatureError{Signature: "expired"}
+		}
+	}
+
+	keyid, _ := input.Params.Get("keyid")
+	id, _ := keyid.(string)
+
+	keys, err := lookupHTTPSigKeys(keyDir, id)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return &SignatureError{Signature: fmt.Sprintf("unknown keyid %q", id)}
+	}
+
+	var alg string
+	if v, ok := input.Params.Get("alg"); ok {
+		if alg, ok = v.(string); !ok {
+			return &SignatureError{Signature: "invalid alg parameter"}
+		}
+	}
+
+	base, err := httpSignatureBase(r.RawRequest, covered, input.Raw)
+	if err != nil {
+		return &SignatureError{Signature: err.Error()}
+	}
+
+	if containsString(covered, "content-digest") && !checkContentDigest(r.RawRequest.Header.Values("Content-Digest"), r.Body) {
+		return &SignatureError{Signature: "content digest mismatch"}
+	}
+
+	for _, key := range keys {
+		if verifyHTTPSigKey(key, alg, base, sig) {
+			return nil
+		}
+	}
+
+	return &SignatureError{Signature: base64.StdEncoding.EncodeToString(sig)}
+}
+
+// httpSignatureBase creates the signature base for the covered components, as
+// defined by RFC 9421, section 2.5.
+func httpSignatureBase(r *http.Request, covered []string, params string) ([]byte, error) {
+	var b bytes.Buffer
+
+	for _, name := range covered {
+		v, err := httpSigComponentValue(r, name)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Fprintf(&b, "\"%s\": %s\n", name, v)
+	}
+
+	fmt.Fprintf(&b, "\"@signature-params\": %s", params)
+
+	return b.Bytes(), nil
+}
+
+// httpSigComponentValue returns the value of a component of the request.
+func httpSigComponentValue(r *http.Request, name string) (string, error) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	switch name {
+	case "@method":
+		return r.Method, nil
+
+	case "@target-uri":
+		return scheme + "://" + r.Host + r.URL.RequestURI(), nil
+
+	case "@authority":
+		host := strings.ToLower(r.Host)
+		host = strings.TrimSuffix(host, map[string]string{"http": ":80", "https": ":443"}[scheme])
+		return host, nil
+
+	case "@scheme":
+		return scheme, nil
+
+	case "@request-target":
+		return r.URL.RequestURI(), nil
+
+	case "@path":
+		if p := r.URL.EscapedPath(); p != "" {
+			return p, nil
+		}
+		return "/", nil
+
+	case "@query":
+		return "?" + r.URL.RawQuery, nil
+	}
+
+	if strings.HasPrefix(name, "@") {
+		return "", fmt.Errorf("unsupported component %q", name)
+	}
+
+	if name != strings.ToLower(name) {
+		return "", fmt.Errorf("component %q must be lowercase", name)
+	}
+
+	raw := r.Header.Values(name)
+	if name == "host" {
+		raw = []string{r.Host}
+	}
+
+	if len(raw) == 0 {
+		return "", fmt.Errorf("missing component %q", name)
+	}
+
+	values := make([]string, len(raw))
+	for i := range raw {
+		values[i] = strings.TrimSpace(raw[i])
+	}
+
+	return strings.Join(values, ", "), nil
+}
+
+// checkContentDigest verifies that the Content-Digest header, as defined by
+// RFC 9530, contains a matching sha-256 or sha-512 digest of the body.
+func checkContentDigest(header []string, body []byte) bool {
+	digests, err := parseSFDictionary(strings.Join(header, ", "))
+	if err != nil {
+		return false
+	}
+
+	var matched bool
+
+	for _, d := range digests {
+		if d.Item == nil {
+			continue
+		}
+
+		v, ok := d.Item.Value.([]byte)
+		if !ok {
+			return false
+		}
+
+		var sum []byte
+
+		switch d.Name {
+		case "sha-256":
+			s := sha256.Sum256(body)
+			sum = s[:]
+		case "sha-512":
+			s := sha512.Sum512(body)
+			sum = s[:]
+		default:
+			continue
+		}
+
+		if !hmac.Equal(v, sum) {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+// verifyHTTPSigKey verifies the signature of base with the given key.  If alg
+// is empty, the algorithm is derived from the key type.
+func verifyHTTPSigKey(key interface{}, alg string, base, sig []byte) bool {
+	switch k := key.(type) {
+	case []byte:
+		if alg != "" && alg != HTTPSigHMACSHA256 {
+			return false
+		}
+
+		mac := hmac.New(sha256.New, k)
+		mac.Write(base)
+		return hmac.Equal(sig, mac.Sum(nil))
+
+	case ed25519.PublicKey:
+		if alg != "" && alg != HTTPSigEd25519 {
+			return false
+		}
+
+		return ed25519.Verify(k, base, sig)
+
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			if alg != "" && alg != HTTPSigECDSAP256SHA256 {
+				return false
+			}
+
+			sum := sha256.Sum256(base)
+			return verifyECDSA(k, sum[:], sig)
+
+		case "P-384":
+			if alg != "" && alg != HTTPSigECDSAP384SHA384 {
+				return false
+			}
+
+			sum := sha512.Sum384(base)
+			return verifyECDSA(k, sum[:], sig)
+		}
+
+	case *rsa.PublicKey:
+		if alg == "" || alg == HTTPSigRSAPSSSHA512 {
+			sum := sha512.Sum512(base)
+			if rsa.VerifyPSS(k, crypto.SHA512, sum[:], sig, &rsa.PSSOptions{SaltLength: 64}) == nil {
+				return true
+			}
+		}
+
+		if alg == "" || alg == HTTPSigRSAV15SHA256 {
+			sum := sha256.Sum256(base)
+			return rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,183 @@
+package hook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSFDictionary(t *testing.T) {
+	in := `sig1=("@method" "content-digest");created=1618884473;keyid="k\"1", sig2=:AAE=:, a, b=?0;x=tok/en;n=-5`
+
+	members, err := parseSFDictionary(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []sfMember{
+		{
+			Name:   "sig1",
+			Items:  []sfItem{{Value: "@method"}, {Value: "content-digest"}},
+			Params: sfParams{{"created", int64(1618884473)}, {"keyid", `k"1`}},
+			Raw:    `("@method" "content-digest");created=1618884473;keyid="k\"1"`,
+		},
+		{Name: "sig2", Item: &sfItem{Value: []byte{0, 1}}, Raw: ":AAE=:"},
+		{Name: "a", Item: &sfItem{Value: true}, Raw: ""},
+		{Name: "b", Item: &sfItem{Value: false, Params: sfParams{{"x", sfToken("tok/en")}, {"n", int64(-5)}}}, Raw: "?0;x=tok/en;n=-5"},
+	}
+
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("failed to parse dictionary:\nexpected %#v\ngot %#v", expected, members)
+	}
+
+	for _, in := range []string{`a=(`, `a="x`, `a=:!:`, `a=1.5`, `A=1`, `a=1,`, `a=1 b=2`} {
+		if _, err := parseSFDictionary(in); err == nil {
+			t.Errorf("expected error parsing %q", in)
+		}
+	}
+}
+
+func TestCheckHTTPMessageSignature(t *testing.T) {
+	dir := t.TempDir()
+
+	// Test keys from RFC 9421, appendix B.1.
+	writeFile := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile("test-shared-secret", "uzvJfB4u3N0Jy4T7NZ75MDVcr8zSTInedJtkgcu46YW4XByzNJjxBdtjUkdJPBtbmHhIDi6pcl8jsasjlTMtDQ==\n")
+	writeFile("test-key-ed25519.pem", "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEAJrQLj5P/89iXES9+vFgrIy29clF9CC/oPPsw3c5D0bs=\n-----END PUBLIC KEY-----\n")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writePublicKeyPEM(t, dir, "test-key-ecc-p256.pem", &ecKey.PublicKey)
+
+	body := `{"hello": "world"}`
+	created := int64(1618884473)
+	now := time.Unix(created, 0).Add(time.Minute)
+
+	// newRequest creates the test request from RFC 9421, appendix B.2.
+	newRequest := func(input, sig string) *http.Request {
+		r := httptest.NewRequest("POST", "http://example.com/foo?param=Value&Pet=dog", strings.NewReader(body))
+		r.Header.Set("Date", "Tue, 20 Apr 2021 02:07:55 GMT")
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Content-Digest", "sha-512=:WZDPaVn/7XgHaAy8pmojAkGWoRx2UFChF41A2svX+TaPm+AbwAgBWnrIiYllu7BNNyealdVLvRwEmTHWXvJwew==:")
+		r.Header.Set("Content-Length", "18")
+		r.Header.Set("Signature-Input", input)
+		r.Header.Set("Signature", sig)
+		return r
+	}
+
+	// signECDSA signs the request with the generated P-256 key.
+	signECDSA := func(params string) (string, string) {
+		r := newRequest("", "")
+		covered := []string{"@method", "@target-uri", "@authority", "content-type", "content-digest"}
+		raw := `("@method" "@target-uri" "@authority" "content-type" "content-digest")` + params
+
+		base, err := httpSignatureBase(r, covered, raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256(base)
+
+		rs, ss, err := ecdsa.Sign(rand.Reader, ecKey, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig := append(leftPad(rs.Bytes(), 32), leftPad(ss.Bytes(), 32)...)
+
+		return "sig1=" + raw, "sig1=:" + base64.StdEncoding.EncodeToString(sig) + ":"
+	}
+
+	hmacInput := `sig-b25=("date" "@authority" "content-type");created=1618884473;keyid="test-shared-secret"`
+	hmacSig := `sig-b25=:pxcQw6G3AjtMBQjwo8XzkZf/bws5LelbaMk5rGIGtE8=:`
+
+	edInput := `sig-b26=("date" "@method" "@path" "@authority" "content-type" "content-length");created=1618884473;keyid="test-key-ed25519"`
+	edSig := `sig-b26=:wqcAqbmYJ2ji2glfAMaRy4gruYYnx2nEFN2HN6jrnDnQCK1u02Gb04v9EDgwUPiu4A0w6vuQv5lIp5WPpBKRCw==:`
+
+	ecInput, ecSig := signECDSA(`;created=1618884473;keyid="test-key-ecc-p256";alg="ecdsa-p256-sha256"`)
+	expiredInput, expiredSig := signECDSA(`;created=1618884473;expires=1618884493;keyid="test-key-ecc-p256"`)
+	noCreatedInput, noCreatedSig := signECDSA(`;keyid="test-key-ecc-p256"`)
+	traversalInput, traversalSig := signECDSA(`;created=1618884473;keyid="../test-key-ecc-p256"`)
+
+	for _, tt := range []struct {
+		desc     string
+		req      *http.Request
+		body     string
+		required []string
+		now      time.Time
+		ok       bool
+		sigErr   bool
+	}{
+		{"hmac-sha256", newRequest(hmacInput, hmacSig), body, nil, now, true, false},
+		{"ed25519", newRequest(edInput, edSig), body, nil, now, true, false},
+		{"ecdsa-p256-sha256", newRequest(ecInput, ecSig), body, []string{"@method", "content-digest"}, now, true, false},
+		{"multiple signatures", newRequest(hmacInput+", "+ecInput, hmacSig+", "+ecSig), body, []string{"content-digest"}, now, true, false},
+		// failures
+		{"missing headers", newRequest("", ""), body, nil, now, false, false},
+		{"modified header", func() *http.Request {
+			r := newRequest(hmacInput, hmacSig)
+			r.Header.Set("Content-Type", "text/plain")
+			return r
+		}(), body, nil, now, false, true},
+		{"modified body", newRequest(ecInput, ecSig), `{"hello": "there"}`, nil, now, false, true},
+		{"required component not covered", newRequest(hmacInput, hmacSig), body, []string{"content-digest"}, now, false, true},
+		{"outdated", newRequest(hmacInput, hmacSig), body, nil, now.Add(10 * time.Minute), false, true},
+		{"expired", newRequest(expiredInput, expiredSig), body, nil, now, false, true},
+		{"no created parameter", newRequest(noCreatedInput, noCreatedSig), body, nil, now, false, true},
+		{"unknown keyid", newRequest(strings.Replace(hmacInput, "test-shared-secret", "other", 1), hmacSig), body, nil, now, false, true},
+		{"keyid outside key-dir", newRequest(traversalInput, traversalSig), body, nil, now, false, true},
+		{"wrong algorithm", newRequest(hmacInput+`;alg="ed25519"`, hmacSig), body, nil, now, false, true},
+		{"malformed", newRequest("sig1=(", hmacSig), body, nil, now, false, true},
+	} {
+		req := &Request{
+			Body:       []byte(tt.body),
+			RawRequest: tt.req,
+		}
+
+		ok, err := CheckHTTPMessageSignature(req, dir, tt.required, DefaultSignatureTolerance, tt.now)
+		if ok != tt.ok || (err != nil && IsSignatureError(err) != tt.sigErr) || (err == nil && tt.sigErr) {
+			t.Errorf("%s failed:\nexpected ok: %v, signature error: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, tt.sigErr, ok, err)
+		}
+	}
+
+	// Without components, the rule requires the default ones.
+	rule := MatchRule{Type: HTTPMessageSignature, KeyDir: dir}
+
+	for _, tt := range []struct {
+		desc       string
+		input, sig string
+		ok         bool
+	}{
+		{"default components covered", ecInput, ecSig, true},
+		{"default components not covered", edInput, edSig, false},
+	} {
+		req := &Request{
+			Body:       []byte(body),
+			RawRequest: newRequest(tt.input, tt.sig),
+			Clock:      func() time.Time { return now },
+		}
+
+		ok, err := rule.Evaluate(req)
+		if ok != tt.ok || (!ok && !IsSignatureError(err)) {
+			t.Errorf("%s failed:\nexpected ok: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, ok, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPRanges is a list of IP networks.
+type IPRanges []*net.IPNet
+
+// ipListFiles caches the IP ranges parsed from ip-list-file files.
+var ipListFiles = newFileCache(func(data []byte) (interface{}, error) {
+	return ParseIPList(data)
+})
+
+// ParseIPRanges parses a list of IP addresses and CIDR ranges separated by
+// whitespace or commas.  A single address is treated as a /32 range for IPv4
+// or a /128 range for IPv6.
+func ParseIPRanges(s string) (IPRanges, error) {
+	var ranges IPRanges
+
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r' }) {
+		n, err := parseIPRange(f)
+		if err != nil {
+			return nil, err
+		}
+
+		ranges = append(ranges, n)
+	}
+
+	return ranges, nil
+}
+
+// ParseIPList parses the contents of an IP list file: IP addresses and CIDR
+// ranges separated by whitespace or commas.  Text after a "#" is ignored.
+func ParseIPList(data []byte) (IPRanges, error) {
+	var ranges IPRanges
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		r, err := ParseIPRanges(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		ranges = append(ranges, r...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ranges, nil
+}
+
+// parseIPRange parses an IP address or CIDR range.
+func parseIPRange(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		return n, nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", s)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+// Contains reports whether ip is in any of the ranges.
+func (r IPRanges) Contains(ip net.IP) bool {
+	for _, n := range r {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseRemoteIP extracts the IP address from a remote address of the form
+// IP:port or [IP]:port.  A bare IP address is accepted as well.
+func parseRemoteIP(remoteAddr string) (net.IP, error) {
+	s := strings.TrimSpace(remoteAddr)
+
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			return ip, nil
+		}
+	}
+
+	// IPv6 addresses will likely be surrounded by [].
+	s = strings.Trim(s, " []")
+
+	if ip := net.ParseIP(s); ip != nil {
+		return ip, nil
+	}
+
+	if i := strings.LastIndex(s, ":"); i != -1 {
+		if ip := net.ParseIP(strings.Trim(s[:i], " []")); ip != nil {
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid IP address found in remote address '%s'", remoteAddr)
+}
+
+// compileIPRanges parses the inline ranges of an ip-whitelist match rule.
+func (r *MatchRule) compileIPRanges() error {
+	var err error
+
+	if r.ipRanges, err = ParseIPRanges(r.IPRange); err != nil {
+		return fmt.Errorf("invalid ip-range: %w", err)
+	}
+
+	if r.denyIPRanges, err = ParseIPRanges(r.DenyIPRange); err != nil {
+		return fmt.Errorf("invalid deny-ip-range: %w", err)
+	}
+
+	r.ipRangesCompiled = true
+
+	return nil
+}
+
+// ipRangeList returns the inline ranges and the ranges of the list file.
+func ipRangeList(inline IPRanges, file string) (IPRanges, error) {
+	if file == "" {
+		return inline, nil
+	}
+
+	v, err := ipListFiles.Get(file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading IP list file: %w", err)
+	}
+
+	return append(inline[:len(inline):len(inline)], v.(IPRanges)...), nil
+}
+
+// checkIPWhitelist evaluates the ip-whitelist match rule.  The remote address
+// must be in the allowed ranges, if any are given, and not in the denied
+// ranges.
+func (r MatchRule) checkIPWhitelist(req *Request) (bool, error) {
+	if !r.ipRangesCompiled {
+		if err := r.compileIPRanges(); err != nil {
+			return false, err
+		}
+	}
+
+	ip, err := parseRemoteIP(req.RawRequest.RemoteAddr)
+	if err != nil {
+		return false, err
+	}
+
+	allow, err := ipRangeList(r.ipRanges, r.IPListFile)
+	if err != nil {
+		return false, err
+	}
+
+	deny, err := ipRangeList(r.denyIPRanges, r.DenyIPListFile)
+	if err != nil {
+		return false, err
+	}
+
+	if deny.Contains(ip) {
+		return false, nil
+	}
+
+	if r.IPRange == "" && r.IPListFile == "" {
+		return r.DenyIPRange != "" || r.DenyIPListFile != "", nil
+	}
+
+	return allow.Contains(ip), nil
+}
@@ -0,0 +1,129 @@
+package hook
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIPList(t *testing.T) {
+	ranges, err := ParseIPList([]byte("# GitHub hooks\n192.30.252.0/22\n185.199.108.0/22, 140.82.112.0/20 # web\n\n2a0a:a440::/29\n2001:db8::1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range ranges {
+		got = append(got, r.String())
+	}
+
+	expected := "192.30.252.0/22 185.199.108.0/22 140.82.112.0/20 2a0a:a440::/29 2001:db8::1/128"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("failed to parse IP list:\nexpected %s\ngot %s", expected, strings.Join(got, " "))
+	}
+
+	if _, err := ParseIPList([]byte("10.0.0.0/8\nexample.com\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error on line 2, got %v", err)
+	}
+}
+
+func TestIPWhitelistLists(t *testing.T) {
+	dir := t.TempDir()
+
+	allowFile := filepath.Join(dir, "allow.txt")
+	if err := os.WriteFile(allowFile, []byte("192.30.252.0/22\n2a0a:a440::/29\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	denyFile := filepath.Join(dir, "deny.txt")
+	if err := os.WriteFile(denyFile, []byte("192.30.253.0/24\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc       string
+		rule       MatchRule
+		remoteAddr string
+		ok         bool
+		err        bool
+	}{
+		{"allow file", MatchRule{IPListFile: allowFile}, "192.30.252.10:443", true, false},
+		{"allow file ipv6", MatchRule{IPListFile: allowFile}, "[2a0a:a440::1]:443", true, false},
+		{"allow file and range", MatchRule{IPListFile: allowFile, IPRange: "10.0.0.1"}, "10.0.0.1:443", true, false},
+		{"deny file", MatchRule{IPListFile: allowFile, DenyIPListFile: denyFile}, "192.30.253.10:443", false, false},
+		{"deny range", MatchRule{IPListFile: allowFile, DenyIPRange: "192.30.252.10"}, "192.30.252.10:443", false, false},
+		{"deny only", MatchRule{DenyIPListFile: denyFile}, "10.0.0.1:443", true, false},
+		{"deny only denied", MatchRule{DenyIPListFile: denyFile}, "192.30.253.1:443", false, false},
+		{"not allowed", MatchRule{IPListFile: allowFile}, "10.0.0.1:443", false, false},
+		{"no ranges", MatchRule{}, "10.0.0.1:443", false, false},
+		// errors
+		{"missing file", MatchRule{IPListFile: allowFile + ".missing"}, "10.0.0.1:443", false, true},
+		{"invalid deny range", MatchRule{DenyIPRange: "10.0.0.1/99"}, "10.0.0.1:443", false, true},
+	} {
+		tt.rule.Type = "ip-whitelist"
+
+		ok, err := tt.rule.Evaluate(&Request{RawRequest: &http.Request{RemoteAddr: tt.remoteAddr}})
+		if ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("%s failed:\nexpected {ok:%v, err:%v}\ngot {ok:%v, err:%v}", tt.desc, tt.ok, tt.err, ok, err)
+		}
+	}
+}
+
+func TestIPListFileReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ips.txt")
+
+	write := func(ranges string, mtime time.Time) {
+		if err := os.WriteFile(file, []byte(ranges), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := MatchRule{Type: "ip-whitelist", IPListFile: file}
+	req := &Request{RawRequest: &http.Request{RemoteAddr: "10.0.0.1:443"}}
+
+	write("192.168.0.0/16\n", time.Now().Add(-time.Hour))
+
+	if ok, _ := r.Evaluate(req); ok {
+		t.Fatal("address should not match the initial list")
+	}
+
+	write("192.168.0.0/16\n10.0.0.0/8\n", time.Now())
+
+	if ok, err := r.Evaluate(req); !ok || err != nil {
+		t.Errorf("address should match after the list file changed: ok: %v, err: %v", ok, err)
+	}
+}
+
+func TestLoadHooksIPRanges(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.json")
+
+	write := func(data string) {
+		if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`[{"id": "ok", "trigger-rule": {"not": {"match": {"type": "ip-whitelist", "ip-range": "10.0.0.0/8 ::1"}}}}]`)
+
+	var hooks Hooks
+	if err := hooks.LoadFromFile(file, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if rule := (*Rules)(hooks[0].TriggerRule.Not).Match; !rule.ipRangesCompiled || len(rule.ipRanges) != 2 {
+		t.Errorf("ip ranges should be parsed when loading hooks: %#v", rule.ipRanges)
+	}
+
+	write(`[{"id": "bad", "trigger-rule": {"and": [{"match": {"type": "ip-whitelist", "ip-range": "10.0.0.0/33"}}]}}]`)
+
+	hooks = nil
+	if err := hooks.LoadFromFile(file, false); err == nil || !strings.Contains(err.Error(), "hook bad: trigger-rule.and[0].match: invalid ip-range") {
+		t.Errorf("expected invalid ip-range error, got %v", err)
+	}
+}
@@ -0,0 +1,326 @@
+package hook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Constants for the supported JWT signing algorithms
+const (
+	JWTAlgHS256 string = "HS256"
+	JWTAlgRS256 string = "RS256"
+	JWTAlgES256 string = "ES256"
+	JWTAlgEdDSA string = "EdDSA"
+)
+
+// JWTOptions describes how a JSON Web Token is verified by the jwt match
+// rule.  Exactly one source of keys must be given: the rule's secret for
+// HS256, a PEM file or a JWKS file.
+type JWTOptions struct {
+	// Algorithms restricts the accepted signing algorithms.  By default, any
+	// algorithm matching the type of the verification key is accepted.
+	Algorithms []string `json:"algorithms,omitempty"`
+
+	// PublicKeyFile is the path to a PEM file containing public keys or
+	// certificates.
+	PublicKeyFile string `json:"public-key-file,omitempty"`
+
+	// JWKSFile is the path to a local JSON Web Key Set file.
+	JWKSFile string `json:"jwks-file,omitempty"`
+
+	// Issuer is the required value of the iss claim.
+	Issuer string `json:"issuer,omitempty"`
+
+	// Audience is the value required to be present in the aud claim.
+	Audience string `json:"audience,omitempty"`
+
+	// Leeway is the allowed clock skew when checking the exp and nbf claims,
+	// such as "30s".
+	Leeway string `json:"leeway,omitempty"`
+}
+
+// jwtKey is a candidate key for verifying a token.
+type jwtKey struct {
+	id  string
+	alg string
+	key interface{}
+}
+
+// CheckJWT verifies the given JSON Web Token and returns its claims.  The
+// token may be prefixed with "Bearer ".
+func CheckJWT(token, secret string, opts *JWTOptions, now time.Time) (map[string]interface{}, error) {
+	if opts == nil {
+		opts = &JWTOptions{}
+	}
+
+	keys, err := opts.keys(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var leeway time.Duration
+	if opts.Leeway != "" {
+		leeway, err = time.ParseDuration(opts.Leeway)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwt leeway %q: %w", opts.Leeway, err)
+		}
+	}
+
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = strings.TrimSpace(token[7:])
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, &SignatureError{Signature: "malformed token"}
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, &SignatureError{Signature: "malformed token header"}
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, &SignatureError{Signature: "malformed token claims"}
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, &SignatureError{Signature: "malformed token signature"}
+	}
+
+	if len(opts.Algorithms) != 0 && !containsString(opts.Algorithms, header.Alg) {
+		return nil, &SignatureError{Signature: parts[2]}
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	var verified bool
+
+	for _, k := range keys {
+		if k.alg != header.Alg || (header.Kid != "" && k.id != "" && k.id != header.Kid) {
+			continue
+		}
+
+		if verifyJWTSignature(k, signed, sig) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, &SignatureError{Signature: parts[2]}
+	}
+
+	if exp, ok := claims["exp"]; ok {
+		t, err := jwtNumericDate(exp)
+		if err != nil {
+			return nil, &SignatureError{Signature: "invalid exp claim"}
+		}
+
+		if !now.Before(t.Add(leeway)) {
+			return nil, &SignatureError{Signature: "expired token"}
+		}
+	}
+
+	if nbf, ok := claims["nbf"]; ok {
+		t, err := jwtNumericDate(nbf)
+		if err != nil {
+			return nil, &SignatureError{Signature: "invalid nbf claim"}
+		}
+
+		if now.Add(leeway).Before(t) {
+			return nil, &SignatureError{Signature: "token not yet valid"}
+		}
+	}
+
+	if opts.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != opts.Issuer {
+			return nil, &SignatureError{Signature: "invalid token issuer"}
+		}
+	}
+
+	if opts.Audience != "" && !jwtHasAudience(claims["aud"], opts.Audience) {
+		return nil, &SignatureError{Signature: "invalid token audience"}
+	}
+
+	return claims, nil
+}
+
+// keys returns the candidate verification keys.  Each key is bound to a
+// single algorithm, so that a token can't choose how its key is interpreted.
+func (o *JWTOptions) keys(secret string) ([]jwtKey, error) {
+	var keys []jwtKey
+
+	if secret != "" {
+		keys = append(keys, jwtKey{alg: JWTAlgHS256, key: []byte(secret)})
+	}
+
+	if o.PublicKeyFile != "" {
+		v, err := publicKeyFiles.Get(o.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading jwt public key file: %w", err)
+		}
+
+		for _, key := range v.([]crypto.PublicKey) {
+			if alg := jwtKeyAlgorithm(key); alg != "" {
+				keys = append(keys, jwtKey{alg: alg, key: key})
+			}
+		}
+	}
+
+	if o.JWKSFile != "" {
+		v, err := jwksFiles.Get(o.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading jwt JWKS file: %w", err)
+		}
+
+		for _, jwk := range v.([]JSONWebKey) {
+			alg := jwtKeyAlgorithm(jwk.Key)
+			if alg == "" || (jwk.Algorithm != "" && jwk.Algorithm != alg) {
+				continue
+			}
+
+			keys = append(keys, jwtKey{id: jwk.KeyID, alg: alg, key: jwk.Key})
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("jwt validation requires a secret, public-key-file or jwks-file")
+	}
+
+	return keys, nil
+}
+
+// jwtKeyAlgorithm returns the signing algorithm used with the given key, or
+// the empty string if the key is not supported.
+func jwtKeyAlgorithm(key interface{}) string {
+	switch k := key.(type) {
+	case []byte:
+		return JWTAlgHS256
+	case *rsa.PublicKey:
+		return JWTAlgRS256
+	case *ecdsa.PublicKey:
+		if k.Curve.Params().Name == "P-256" {
+			return JWTAlgES256
+		}
+	case ed25519.PublicKey:
+		return JWTAlgEdDSA
+	}
+
+	return ""
+}
+
+// verifyJWTSignature verifies the signature of signed using the given key.
+func verifyJWTSignature(k jwtKey, signed, sig []byte) bool {
+	sum := sha256.Sum256(signed)
+
+	switch key := k.key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(signed)
+		return hmac.Equal(sig, mac.Sum(nil))
+
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+
+	case *ecdsa.PublicKey:
+		// JWS uses the fixed-size R || S encoding rather than ASN.1.
+		if len(sig) != 64 {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+
+		return ecdsa.Verify(key, sum[:], r, s)
+
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, signed, sig)
+	}
+
+	return false
+}
+
+// decodeJWTSegment decodes a base64url-encoded JSON segment of a token.
+func decodeJWTSegment(s string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	return decoder.Decode(v)
+}
+
+// maxJWTNumericDate is the NumericDate of the year 10000.
+const maxJWTNumericDate = 253402300800
+
+// jwtNumericDate converts a NumericDate claim into a time.
+func jwtNumericDate(v interface{}) (time.Time, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, errors.New("NumericDate is not a number")
+	}
+
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// Bound the date so that converting it can not overflow.
+	if math.IsNaN(f) || math.Abs(f) >= maxJWTNumericDate {
+		return time.Time{}, errors.New("NumericDate is out of range")
+	}
+
+	sec := math.Floor(f)
+
+	return time.Unix(int64(sec), int64((f-sec)*float64(time.Second))), nil
+}
+
+// jwtHasAudience reports whether the aud claim contains the given audience.
+func jwtHasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, a := range v {
+			if s, ok := a.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// containsString reports whether s is in list.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
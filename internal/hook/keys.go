@@ -0,0 +1,206 @@
+package hook
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// publicKeyFiles caches the public keys parsed from PEM files.
+var publicKeyFiles = newFileCache(func(data []byte) (interface{}, error) {
+	return ParsePublicKeysPEM(data)
+})
+
+// jwksFiles caches the keys parsed from JSON Web Key Set files.
+var jwksFiles = newFileCache(func(data []byte) (interface{}, error) {
+	return ParseJWKS(data)
+})
+
+// ParsePublicKeysPEM parses all public keys and certificates in the given PEM
+// data.  PKIX ("PUBLIC KEY"), PKCS #1 ("RSA PUBLIC KEY") and X.509
+// certificate blocks are supported.
+func ParsePublicKeysPEM(data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+
+	for {
+		var block *pem.Block
+
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+
+		case "RSA PUBLIC KEY":
+			key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, cert.PublicKey)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no public keys found in PEM data")
+	}
+
+	return keys, nil
+}
+
+// JSONWebKey is a single key of a JSON Web Key Set, as defined by RFC 7517.
+type JSONWebKey struct {
+	KeyID     string
+	Algorithm string
+	Key       interface{}
+}
+
+// ParseJWKS parses a JSON Web Key Set.  RSA, EC (P-256), OKP (Ed25519) and
+// oct keys are supported; keys of other types are skipped.
+func ParseJWKS(data []byte) ([]JSONWebKey, error) {
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error parsing JWKS: %w", err)
+	}
+
+	keys := make([]JSONWebKey, 0, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		if use, ok := jwk["use"]; ok && use != "sig" {
+			continue
+		}
+
+		key, err := parseJWK(jwk)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing JWKS key %q: %w", jwk["kid"], err)
+		}
+
+		if key == nil {
+			continue
+		}
+
+		keys = append(keys, JSONWebKey{
+			KeyID:     jwk["kid"],
+			Algorithm: jwk["alg"],
+			Key:       key,
+		})
+	}
+
+	return keys, nil
+}
+
+// parseJWK returns the key described by jwk, or nil if the key type is not
+// supported.
+func parseJWK(jwk map[string]string) (interface{}, error) {
+	b64 := func(name string) ([]byte, error) {
+		v, ok := jwk[name]
+		if !ok {
+			return nil, fmt.Errorf("missing %q parameter", name)
+		}
+		return base64.RawURLEncoding.DecodeString(v)
+	}
+
+	switch jwk["kty"] {
+	case "RSA":
+		n, err := b64("n")
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := b64("e")
+		if err != nil {
+			return nil, err
+		}
+
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent is too large")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+
+	case "EC":
+		if jwk["crv"] != "P-256" {
+			return nil, nil
+		}
+
+		x, err := b64("x")
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := b64("y")
+		if err != nil {
+			return nil, err
+		}
+
+		// Use the uncompressed point encoding so the point is validated.
+		point := append([]byte{4}, append(leftPad(x, 32), leftPad(y, 32)...)...)
+
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case "OKP":
+		if jwk["crv"] != "Ed25519" {
+			return nil, nil
+		}
+
+		x, err := b64("x")
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+
+		return ed25519.PublicKey(x), nil
+
+	case "oct":
+		return b64("k")
+	}
+
+	return nil, nil
+}
+
+// leftPad pads b with leading zeros to the given size.
+func leftPad(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	return append(make([]byte, size-len(b)), b...)
+}
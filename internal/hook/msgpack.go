@@ -0,0 +1,156 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// maxDecodeDepth limits the nesting of MessagePack and CBOR values.
+const maxDecodeDepth = 1000
+
+var errMaxDecodeDepth = errors.New("maximum nesting depth exceeded")
+
+// floatNumber converts a floating point value to a json.Number.
+func floatNumber(f float64) (json.Number, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("unsupported floating point value %v", f)
+	}
+
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+}
+
+// mapKey converts a decoded map key to a string.
+func mapKey(k interface{}) (string, error) {
+	switch k := k.(type) {
+	case string:
+		return k, nil
+	case json.Number:
+		return k.String(), nil
+	case bool:
+		return strconv.FormatBool(k), nil
+	}
+
+	return "", fmt.Errorf("unsupported map key type %T", k)
+}
+
+// binaryScalar converts a scalar decoded from MessagePack or CBOR to the
+// types produced by encoding/json with UseNumber.
+func binaryScalar(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float32:
+		return floatNumber(float64(v))
+	case float64:
+		return floatNumber(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	}
+
+	return nil, fmt.Errorf("unsupported value type %T", v)
+}
+
+// decodeMsgPack decodes a MessagePack value into the types produced by
+// encoding/json with UseNumber: maps with string keys, slices, strings,
+// json.Number, bool and nil.  Binary data is decoded as a string and
+// timestamps as RFC 3339 strings.
+func decodeMsgPack(data []byte) (interface{}, error) {
+	r := bytes.NewReader(data)
+
+	v, err := msgpackValue(msgpack.NewDecoder(r), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("offset %d: unexpected data after value", len(data)-r.Len())
+	}
+
+	return v, nil
+}
+
+// msgpackValue decodes the next value.  Maps and arrays are walked here,
+// rather than by the decoder, to limit their nesting and to convert map keys.
+func msgpackValue(d *msgpack.Decoder, depth int) (interface{}, error) {
+	c, err := d.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case msgpcode.IsFixedMap(c), c == msgpcode.Map16, c == msgpcode.Map32:
+		if depth >= maxDecodeDepth {
+			return nil, errMaxDecodeDepth
+		}
+
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]interface{})
+
+		for i := 0; i < n; i++ {
+			k, err := msgpackValue(d, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			key, err := mapKey(k)
+			if err != nil {
+				return nil, err
+			}
+
+			if m[key], err = msgpackValue(d, depth+1); err != nil {
+				return nil, err
+			}
+		}
+
+		return m, nil
+
+	case msgpcode.IsFixedArray(c), c == msgpcode.Array16, c == msgpcode.Array32:
+		if depth >= maxDecodeDepth {
+			return nil, errMaxDecodeDepth
+		}
+
+		n, err := d.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+
+		// The length is not trusted for allocation.
+		a := []interface{}{}
+
+		for i := 0; i < n; i++ {
+			v, err := msgpackValue(d, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			a = append(a, v)
+		}
+
+		return a, nil
+	}
+
+	v, err := d.DecodeInterfaceLoose()
+	if err != nil {
+		return nil, err
+	}
+
+	return binaryScalar(v)
+}
@@ -0,0 +1,65 @@
+package hook
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMsgPack(t *testing.T) {
+	for _, tt := range []struct {
+		data  string
+		value interface{}
+	}{
+		{"00", json.Number("0")},
+		{"7f", json.Number("127")},
+		{"e0", json.Number("-32")},
+		{"cd012c", json.Number("300")},
+		{"cfffffffffffffffff", json.Number("18446744073709551615")},
+		{"d1ff38", json.Number("-200")},
+		{"d38000000000000000", json.Number("-9223372036854775808")},
+		{"ca3fc00000", json.Number("1.5")},
+		{"cb3ff199999999999a", json.Number("1.1")},
+		{"c0", nil},
+		{"c2", false},
+		{"c3", true},
+		{"a46d61696e", "main"},
+		{"d9046d61696e", "main"},
+		{"c4026162", "ab"},
+		{"d6ff00000000", "1970-01-01T00:00:00Z"},
+		{"c70cff000003e80000000065920080", "2024-01-01T00:00:00.000001Z"},
+		{"9201a0", []interface{}{json.Number("1"), ""}},
+		{"dc000100", []interface{}{json.Number("0")}},
+		{"82a3726566a46d61696e01c3", map[string]interface{}{"ref": "main", "1": true}},
+		{"de0001a16181a162c0", map[string]interface{}{"a": map[string]interface{}{"b": nil}}},
+	} {
+		data, _ := hex.DecodeString(tt.data)
+
+		v, err := decodeMsgPack(data)
+		if err != nil || !reflect.DeepEqual(v, tt.value) {
+			t.Errorf("failed to decode %s:\nexpected %#v\ngot %#v, err: %v", tt.data, tt.value, v, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		data, err string
+	}{
+		{"", "EOF"},
+		{"c1", "unknown code c1"},
+		{"a56162", "unexpected EOF"},
+		{"0000", "offset 1: unexpected data after value"},
+		{"dd ffffffff", "EOF"},
+		{"d40100", "unknown ext id=1"},
+		{"81c0c0", "unsupported map key type <nil>"},
+		{"cb7ff0000000000000", "unsupported floating point value +Inf"},
+		{strings.Repeat("91", 1001) + "c0", "maximum nesting depth exceeded"},
+	} {
+		data, _ := hex.DecodeString(strings.ReplaceAll(tt.data, " ", ""))
+
+		if _, err := decodeMsgPack(data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("failed to reject %s:\nexpected error containing %q\ngot %v", tt.data, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pathSegment is a segment of a hook path pattern: either a literal or a
+// parameter matching any single non-empty segment.
+type pathSegment struct {
+	literal string
+	param   string
+}
+
+// parsePathPattern parses a hook path pattern such as
+// "deploy/{env}/{service}".  Each segment is either a literal or a parameter
+// name in braces.
+func parsePathPattern(p string) ([]pathSegment, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil, fmt.Errorf("path must not be empty")
+	}
+
+	var segments []pathSegment
+
+	params := make(map[string]bool)
+
+	for _, s := range strings.Split(p, "/") {
+		switch {
+		case s == "":
+			return nil, fmt.Errorf("invalid path %q: empty segment", p)
+
+		case strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}"):
+			name := s[1 : len(s)-1]
+			if name == "" || strings.ContainsAny(name, "{}") {
+				return nil, fmt.Errorf("invalid path %q: invalid parameter %q", p, s)
+			}
+
+			if params[name] {
+				return nil, fmt.Errorf("invalid path %q: duplicate parameter %q", p, name)
+			}
+
+			params[name] = true
+			segments = append(segments, pathSegment{param: name})
+
+		case strings.ContainsAny(s, "{}"):
+			return nil, fmt.Errorf("invalid path %q: parameters must span a whole segment: %q", p, s)
+
+		default:
+			segments = append(segments, pathSegment{literal: s})
+		}
+	}
+
+	return segments, nil
+}
+
+// matchPath matches p against the path pattern of the hook and returns the
+// captured parameters.
+func (h *Hook) matchPath(p string) (map[string]interface{}, bool) {
+	pattern := h.pathPattern
+	if pattern == nil {
+		var err error
+
+		if pattern, err = parsePathPattern(h.Path); err != nil {
+			return nil, false
+		}
+	}
+
+	parts := strings.Split(p, "/")
+	if len(parts) != len(pattern) {
+		return nil, false
+	}
+
+	params := make(map[string]interface{})
+
+	for i, s := range pattern {
+		switch {
+		case s.param != "":
+			if parts[i] == "" {
+				return nil, false
+			}
+
+			params[s.param] = parts[i]
+
+		case parts[i] != s.literal:
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// pathPatternsOverlap reports whether some path matches both patterns.
+func pathPatternsOverlap(a, b []pathSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].param == "" && b[i].param == "" && a[i].literal != b[i].literal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// OverlapsPath reports whether both hooks have a path pattern and some path
+// matches both of them.  Overlapping patterns are rejected when hooks are
+// loaded, so that the hook serving a path does not depend on their order.
+func (h *Hook) OverlapsPath(other *Hook) bool {
+	if h.Path == "" || other.Path == "" {
+		return false
+	}
+
+	a, b := h.pathPattern, other.pathPattern
+
+	if a == nil {
+		var err error
+
+		if a, err = parsePathPattern(h.Path); err != nil {
+			return false
+		}
+	}
+
+	if b == nil {
+		var err error
+
+		if b, err = parsePathPattern(other.Path); err != nil {
+			return false
+		}
+	}
+
+	return pathPatternsOverlap(a, b)
+}
+
+// MatchPathID returns the hook without a path whose ID is p, or nil.  Hooks
+// without a path are served at their ID, which takes precedence over path
+// patterns.
+func (h *Hooks) MatchPathID(p string) *Hook {
+	for i := range *h {
+		if (*h)[i].Path == "" && (*h)[i].ID == p {
+			return &(*h)[i]
+		}
+	}
+
+	return nil
+}
+
+// MatchPathPattern returns the hook whose path pattern matches p, relative
+// to the URL prefix, along with the captured parameters, or nil.
+func (h *Hooks) MatchPathPattern(p string) (*Hook, map[string]interface{}) {
+	for i := range *h {
+		if (*h)[i].Path == "" {
+			continue
+		}
+
+		if params, ok := (*h)[i].matchPath(p); ok {
+			return &(*h)[i], params
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,131 @@
+package hook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHooksMatchPathID(t *testing.T) {
+	hooks := Hooks{
+		{ID: "deploy"},
+		{ID: "release", Path: "release/{version}"},
+	}
+
+	for _, tt := range []struct {
+		path, id string
+	}{
+		{"deploy", "deploy"},
+		// mismatches
+		{"release", ""},
+		{"release/v1.2.3", ""},
+		{"deploy/prod", ""},
+	} {
+		var id string
+		if h := hooks.MatchPathID(tt.path); h != nil {
+			id = h.ID
+		}
+
+		if id != tt.id {
+			t.Errorf("failed to match %q:\nexpected id: %q\ngot id: %q", tt.path, tt.id, id)
+		}
+	}
+}
+
+func TestHooksMatchPathPattern(t *testing.T) {
+	hooks := Hooks{
+		{ID: "deploy"},
+		{ID: "deploy-service", Path: "deploy/{env}/{service}"},
+		{ID: "release", Path: "/release/{version}/"},
+	}
+
+	for _, tt := range []struct {
+		path   string
+		id     string
+		params map[string]interface{}
+	}{
+		{"deploy/prod/api", "deploy-service", map[string]interface{}{"env": "prod", "service": "api"}},
+		{"deploy/staging/api", "deploy-service", map[string]interface{}{"env": "staging", "service": "api"}},
+		{"release/v1.2.3", "release", map[string]interface{}{"version": "v1.2.3"}},
+		// mismatches
+		{"deploy", "", nil},
+		{"deploy/prod", "", nil},
+		{"deploy/prod/api/extra", "", nil},
+		{"deploy//api", "", nil},
+		{"release", "", nil},
+		{"release/", "", nil},
+		{"deploy-service", "", nil},
+	} {
+		h, params := hooks.MatchPathPattern(tt.path)
+
+		var id string
+		if h != nil {
+			id = h.ID
+		}
+
+		if id != tt.id || !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("failed to match %q:\nexpected {id:%q, params:%v}\ngot {id:%q, params:%v}", tt.path, tt.id, tt.params, id, params)
+		}
+	}
+}
+
+func TestHookOverlapsPath(t *testing.T) {
+	for _, tt := range []struct {
+		a, b    string
+		overlap bool
+	}{
+		{"deploy/{env}", "deploy/{service}", true},
+		{"deploy/{env}", "/deploy/prod/", true},
+		{"deploy/{env}/api", "deploy/prod/{service}", true},
+		{"deploy/prod", "deploy/prod", true},
+		{"deploy/{env}", "release/{version}", false},
+		{"deploy/{env}", "deploy/{env}/{service}", false},
+		{"deploy/prod/{service}", "deploy/staging/{service}", false},
+		{"deploy/{env}", "", false},
+	} {
+		a, b := &Hook{Path: tt.a}, &Hook{Path: tt.b}
+
+		if a.OverlapsPath(b) != tt.overlap || b.OverlapsPath(a) != tt.overlap {
+			t.Errorf("failed to check %q and %q:\nexpected overlap: %v", tt.a, tt.b, tt.overlap)
+		}
+	}
+}
+
+func TestParsePathPattern(t *testing.T) {
+	for _, tt := range []struct {
+		path, err string
+	}{
+		{"", "path must not be empty"},
+		{"/", "path must not be empty"},
+		{"deploy//{env}", "empty segment"},
+		{"deploy/{}", `invalid parameter "{}"`},
+		{"deploy/{a{b}", `invalid parameter "{a{b}"`},
+		{"deploy/{env}/{env}", `duplicate parameter "env"`},
+		{"deploy/v{version}", `parameters must span a whole segment: "v{version}"`},
+	} {
+		if _, err := parsePathPattern(tt.path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("failed to reject %q:\nexpected error containing %q\ngot %v", tt.path, tt.err, err)
+		}
+	}
+}
+
+func TestArgumentGetPath(t *testing.T) {
+	r := &Request{PathParameters: map[string]interface{}{"env": "prod"}}
+
+	if v, err := (&Argument{Source: SourcePath, Name: "env"}).Get(r); err != nil || v != "prod" {
+		t.Errorf("failed to get path parameter: value: %q, err: %v", v, err)
+	}
+
+	if _, err := (&Argument{Source: SourcePath, Name: "service"}).Get(r); err == nil {
+		t.Error("expected error for missing path parameter")
+	}
+
+	rule, err := CompileExpr(`path.env == "prod" && !has(path.service)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := rule.Evaluate(r); !ok || err != nil {
+		t.Errorf("failed to evaluate expr with path parameters: ok: %v, err: %v", ok, err)
+	}
+}
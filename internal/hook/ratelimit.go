@@ -0,0 +1,96 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Constants for the RateLimit key type
+const (
+	RateLimitGlobal     string = "global"
+	RateLimitRemoteAddr string = "remote-addr"
+	RateLimitParameter  string = "parameter"
+)
+
+// RateLimit describes a token bucket limiting how often a hook can be
+// triggered.
+type RateLimit struct {
+	// Requests is the number of requests allowed per Period.
+	Requests int `json:"requests"`
+
+	// Period is the duration over which Requests are allowed.  Defaults to
+	// one minute.
+	Period string `json:"period,omitempty"`
+
+	// Burst is the maximum number of requests allowed at once.  Defaults to
+	// Requests.
+	Burst int `json:"burst,omitempty"`
+
+	// Key selects how requests are grouped into buckets: "global" (the
+	// default), "remote-addr" or "parameter".
+	Key string `json:"key,omitempty"`
+
+	// Parameter is the value used as the bucket key when Key is "parameter".
+	Parameter *Argument `json:"parameter,omitempty"`
+}
+
+// Limits returns the rate in requests per second and the burst size of the
+// rate limit.
+func (rl *RateLimit) Limits() (float64, int, error) {
+	if rl.Requests <= 0 {
+		return 0, 0, errors.New("rate-limit requests must be greater than zero")
+	}
+
+	period := time.Minute
+	if rl.Period != "" {
+		var err error
+
+		period, err = time.ParseDuration(rl.Period)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid rate-limit period %q: %w", rl.Period, err)
+		}
+
+		if period <= 0 {
+			return 0, 0, fmt.Errorf("invalid rate-limit period %q: must be positive", rl.Period)
+		}
+	}
+
+	burst := rl.Burst
+	if burst <= 0 {
+		burst = rl.Requests
+	}
+
+	return float64(rl.Requests) / period.Seconds(), burst, nil
+}
+
+// BucketKey returns the key of the bucket the request is counted against.
+func (rl *RateLimit) BucketKey(r *Request) (string, error) {
+	switch rl.Key {
+	case "", RateLimitGlobal:
+		return "", nil
+
+	case RateLimitRemoteAddr:
+		if r == nil || r.RawRequest == nil {
+			return "", errors.New("request is nil")
+		}
+
+		host, _, err := net.SplitHostPort(r.RawRequest.RemoteAddr)
+		if err != nil {
+			// Unix sockets and named pipes have no port.
+			return r.RawRequest.RemoteAddr, nil
+		}
+
+		return host, nil
+
+	case RateLimitParameter:
+		if rl.Parameter == nil {
+			return "", errors.New("rate-limit parameter is not defined")
+		}
+
+		return rl.Parameter.Get(r)
+	}
+
+	return "", fmt.Errorf("invalid rate-limit key %q", rl.Key)
+}
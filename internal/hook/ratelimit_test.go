@@ -0,0 +1,63 @@
+package hook
+
+import (
+	"net/http"
+	"testing"
+)
+
+var rateLimitLimitsTests = []struct {
+	rl    RateLimit
+	rate  float64
+	burst int
+	ok    bool
+}{
+	{RateLimit{Requests: 60}, 1, 60, true},
+	{RateLimit{Requests: 10, Period: "1s", Burst: 2}, 10, 2, true},
+	{RateLimit{Requests: 1, Period: "1h"}, 1.0 / 3600, 1, true},
+	// failures
+	{RateLimit{}, 0, 0, false},
+	{RateLimit{Requests: 1, Period: "often"}, 0, 0, false},
+	{RateLimit{Requests: 1, Period: "-1s"}, 0, 0, false},
+}
+
+func TestRateLimitLimits(t *testing.T) {
+	for _, tt := range rateLimitLimitsTests {
+		rate, burst, err := tt.rl.Limits()
+		if (err == nil) != tt.ok || rate != tt.rate || burst != tt.burst {
+			t.Errorf("failed to get limits for %+v:\nexpected {rate:%v, burst:%v, ok:%v}\ngot {rate:%v, burst:%v, err:%v}", tt.rl, tt.rate, tt.burst, tt.ok, rate, burst, err)
+		}
+	}
+}
+
+var rateLimitBucketKeyTests = []struct {
+	rl         RateLimit
+	remoteAddr string
+	payload    map[string]interface{}
+	value      string
+	ok         bool
+}{
+	{RateLimit{}, "10.0.0.1:1234", nil, "", true},
+	{RateLimit{Key: "global"}, "10.0.0.1:1234", nil, "", true},
+	{RateLimit{Key: "remote-addr"}, "10.0.0.1:1234", nil, "10.0.0.1", true},
+	{RateLimit{Key: "remote-addr"}, "[2001:db8::1]:1234", nil, "2001:db8::1", true},
+	{RateLimit{Key: "remote-addr"}, "@", nil, "@", true},
+	{RateLimit{Key: "parameter", Parameter: &Argument{Source: "payload", Name: "repository.full_name"}}, "10.0.0.1:1234", map[string]interface{}{"repository": map[string]interface{}{"full_name": "a/b"}}, "a/b", true},
+	// failures
+	{RateLimit{Key: "parameter"}, "10.0.0.1:1234", nil, "", false},
+	{RateLimit{Key: "parameter", Parameter: &Argument{Source: "payload", Name: "repository.full_name"}}, "10.0.0.1:1234", map[string]interface{}{}, "", false},
+	{RateLimit{Key: "sender"}, "10.0.0.1:1234", nil, "", false},
+}
+
+func TestRateLimitBucketKey(t *testing.T) {
+	for _, tt := range rateLimitBucketKeyTests {
+		r := &Request{
+			Payload:    tt.payload,
+			RawRequest: &http.Request{RemoteAddr: tt.remoteAddr},
+		}
+
+		value, err := tt.rl.BucketKey(r)
+		if (err == nil) != tt.ok || value != tt.value {
+			t.Errorf("failed to get bucket key for %+v:\nexpected {value:%#v, ok:%v}\ngot {value:%#v, err:%v}", tt.rl, tt.value, tt.ok, value, err)
+		}
+	}
+}
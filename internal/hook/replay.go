@@ -0,0 +1,57 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultReplayTTL is how long delivery IDs are remembered if the replay
+// protection does not specify a TTL.
+const DefaultReplayTTL = 24 * time.Hour
+
+// ReplayProtection describes how duplicate deliveries of a hook are detected.
+type ReplayProtection struct {
+	// ID is the value identifying a delivery, such as the X-GitHub-Delivery
+	// header.
+	ID Argument `json:"id"`
+
+	// TTL is how long delivery IDs are remembered.  Defaults to 24 hours.
+	TTL string `json:"ttl,omitempty"`
+
+	// File is the path of a file the delivery IDs are persisted to, so that
+	// they survive restarts.
+	File string `json:"file,omitempty"`
+}
+
+// Duration returns how long delivery IDs are remembered.
+func (rp *ReplayProtection) Duration() (time.Duration, error) {
+	if rp.TTL == "" {
+		return DefaultReplayTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(rp.TTL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid replay-protection ttl %q: %w", rp.TTL, err)
+	}
+
+	if ttl <= 0 {
+		return 0, fmt.Errorf("invalid replay-protection ttl %q: must be positive", rp.TTL)
+	}
+
+	return ttl, nil
+}
+
+// DeliveryID returns the ID of the delivery.
+func (rp *ReplayProtection) DeliveryID(r *Request) (string, error) {
+	id, err := rp.ID.Get(r)
+	if err != nil {
+		return "", err
+	}
+
+	if id == "" {
+		return "", errors.New("empty delivery ID")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,49 @@
+package hook
+
+import (
+	"testing"
+	"time"
+)
+
+var replayDurationTests = []struct {
+	rp  ReplayProtection
+	ttl time.Duration
+	ok  bool
+}{
+	{ReplayProtection{}, 24 * time.Hour, true},
+	{ReplayProtection{TTL: "1h"}, time.Hour, true},
+	// failures
+	{ReplayProtection{TTL: "forever"}, 0, false},
+	{ReplayProtection{TTL: "0s"}, 0, false},
+}
+
+func TestReplayProtectionDuration(t *testing.T) {
+	for _, tt := range replayDurationTests {
+		ttl, err := tt.rp.Duration()
+		if (err == nil) != tt.ok || ttl != tt.ttl {
+			t.Errorf("failed to get ttl for %+v:\nexpected {ttl:%v, ok:%v}\ngot {ttl:%v, err:%v}", tt.rp, tt.ttl, tt.ok, ttl, err)
+		}
+	}
+}
+
+var replayDeliveryIDTests = []struct {
+	headers map[string]interface{}
+	value   string
+	ok      bool
+}{
+	{map[string]interface{}{"X-Github-Delivery": "72d3162e-cc78-11e3-81ab-4c9367dc0958"}, "72d3162e-cc78-11e3-81ab-4c9367dc0958", true},
+	// failures
+	{map[string]interface{}{"X-Github-Delivery": ""}, "", false},
+	{map[string]interface{}{}, "", false},
+}
+
+func TestReplayProtectionDeliveryID(t *testing.T) {
+	rp := ReplayProtection{ID: Argument{Source: "header", Name: "X-GitHub-Delivery"}}
+
+	for _, tt := range replayDeliveryIDTests {
+		id, err := rp.DeliveryID(&Request{Headers: tt.headers})
+		if (err == nil) != tt.ok || id != tt.value {
+			t.Errorf("failed to get delivery ID from %v:\nexpected {value:%q, ok:%v}\ngot {value:%q, err:%v}", tt.headers, tt.value, tt.ok, id, err)
+		}
+	}
+}
@@ -2,13 +2,21 @@ package hook
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 	"unicode"
 
+	"github.com/adnanh/webhook/internal/middleware"
 	"github.com/clbanning/mxj/v2"
+	"github.com/ghodss/yaml"
 )
 
 // Request represents a webhook request.
@@ -16,12 +24,19 @@ type Request struct {
 	// The request ID set by the RequestID middleware.
 	ID string
 
+	// HookID is the ID of the matched hook.
+	HookID string
+
 	// The Content-Type of the request.
 	ContentType string
 
 	// The raw request body.
 	Body []byte
 
+	// CompressedBody is the request body as received, if Body was
+	// decompressed.
+	CompressedBody []byte
+
 	// Headers is a map of the parsed headers.
 	Headers map[string]interface{}
 
@@ -31,23 +46,133 @@ type Request struct {
 	// Payload is a map of the parsed payload.
 	Payload map[string]interface{}
 
+	// HeaderValues, QueryValues and FormValues hold all values of repeated
+	// headers, query parameters and form fields.  Headers, Query and Payload
+	// only keep the first value of each.
+	HeaderValues map[string][]string
+	QueryValues  map[string][]string
+	FormValues   map[string][]string
+
+	// PathParameters is a map of the parameters captured from the URL path
+	// by the path pattern of the hook.
+	PathParameters map[string]interface{}
+
+	// JWTClaims is a map of the claims of the last token verified by a jwt
+	// match rule.
+	JWTClaims map[string]interface{}
+
 	// The underlying HTTP request.
 	RawRequest *http.Request
 
 	// Treat signature errors as simple validate failures.
 	AllowSignatureErrors bool
+
+	// AuthChallenge is the WWW-Authenticate challenge of the failed
+	// basic-auth or bearer-token match rule which decided that the rules
+	// evaluated last did not match.  It is empty if they matched.
+	AuthChallenge string
+
+	// TraceRules enables recording the evaluation of the trigger rules in
+	// RuleTrace.
+	TraceRules bool
+
+	// RuleTrace is the evaluation trace of the trigger rules.
+	RuleTrace *RuleTrace
+
+	// traceNode is the trace of the rule being evaluated.
+	traceNode *RuleTrace
+
+	// Clock returns the current time for time-based rules.  If nil,
+	// time.Now is used.
+	Clock func() time.Time
+}
+
+// RequestValue returns the value of a key of the request source.  Values
+// missing from the request, such as the user agent, are empty.
+func (r *Request) RequestValue(key string) (string, error) {
+	if r == nil || r.RawRequest == nil {
+		return "", errors.New("request is nil")
+	}
+
+	req := r.RawRequest
+
+	switch strings.ToLower(key) {
+	case RequestRemoteAddr:
+		return req.RemoteAddr, nil
+	case RequestMethod:
+		return req.Method, nil
+	case RequestHost:
+		return req.Host, nil
+	case RequestPath:
+		return req.URL.Path, nil
+	case RequestRawQuery:
+		return req.URL.RawQuery, nil
+	case RequestScheme:
+		if req.TLS != nil {
+			return "https", nil
+		}
+
+		return "http", nil
+	case RequestProto:
+		return req.Proto, nil
+	case RequestContentLength:
+		if req.ContentLength < 0 {
+			return "", nil
+		}
+
+		return strconv.FormatInt(req.ContentLength, 10), nil
+	case RequestContentType:
+		return req.Header.Get("Content-Type"), nil
+	case RequestUserAgent:
+		return req.UserAgent(), nil
+	case RequestTLSVersion:
+		if req.TLS == nil {
+			return "", nil
+		}
+
+		return tls.VersionName(req.TLS.Version), nil
+	case RequestID:
+		if r.ID != "" {
+			return r.ID, nil
+		}
+
+		return middleware.GetReqID(req.Context()), nil
+	case RequestHookID:
+		return r.HookID, nil
+	}
+
+	return "", fmt.Errorf("unsupported request key: %q", key)
+}
+
+// now returns the current time according to the request's clock.
+func (r *Request) now() time.Time {
+	if r == nil || r.Clock == nil {
+		return time.Now()
+	}
+
+	return r.Clock()
 }
 
 func (r *Request) ParseJSONPayload() error {
-	decoder := json.NewDecoder(bytes.NewReader(r.Body))
+	if err := r.parseJSON(r.Body); err != nil {
+		return fmt.Errorf("error parsing JSON payload %+v", err)
+	}
+
+	return nil
+}
+
+// parseJSON sets the payload to the decoded JSON data.  Arrays are stored
+// under the "root" key.
+func (r *Request) parseJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.UseNumber()
 
 	var firstChar byte
-	for i := 0; i < len(r.Body); i++ {
-		if unicode.IsSpace(rune(r.Body[i])) {
+	for i := 0; i < len(data); i++ {
+		if unicode.IsSpace(rune(data[i])) {
 			continue
 		}
-		firstChar = r.Body[i]
+		firstChar = data[i]
 		break
 	}
 
@@ -55,7 +180,7 @@ func (r *Request) ParseJSONPayload() error {
 		var arrayPayload interface{}
 		err := decoder.Decode(&arrayPayload)
 		if err != nil {
-			return fmt.Errorf("error parsing JSON array payload %+v", err)
+			return err
 		}
 
 		r.Payload = make(map[string]interface{}, 1)
@@ -63,15 +188,106 @@ func (r *Request) ParseJSONPayload() error {
 	} else {
 		err := decoder.Decode(&r.Payload)
 		if err != nil {
-			return fmt.Errorf("error parsing JSON payload %+v", err)
+			return err
 		}
 	}
 
 	return nil
 }
 
+// ParseYAMLPayload parses a YAML payload.  The payload is converted to JSON
+// first, so it is represented just like a JSON payload.
+func (r *Request) ParseYAMLPayload() error {
+	data, err := yaml.YAMLToJSON(r.Body)
+	if err == nil {
+		err = r.parseJSON(data)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error parsing YAML payload: %+v", err)
+	}
+
+	return nil
+}
+
+// ParseNDJSONPayload parses a newline-delimited JSON payload.  The values are
+// stored as an array under the "root" key; empty lines are ignored.
+func (r *Request) ParseNDJSONPayload() error {
+	var values []interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(r.Body))
+	decoder.UseNumber()
+
+	for {
+		var v interface{}
+
+		err := decoder.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("error parsing NDJSON payload: %+v", err)
+		}
+
+		values = append(values, v)
+	}
+
+	if values == nil {
+		values = []interface{}{}
+	}
+
+	r.Payload = map[string]interface{}{"root": values}
+
+	return nil
+}
+
+// ParseMsgPackPayload parses a MessagePack payload.
+func (r *Request) ParseMsgPackPayload() error {
+	v, err := decodeMsgPack(r.Body)
+	if err == nil {
+		err = r.setPayload(v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error parsing MessagePack payload: %+v", err)
+	}
+
+	return nil
+}
+
+// ParseCBORPayload parses a CBOR payload.
+func (r *Request) ParseCBORPayload() error {
+	v, err := decodeCBOR(r.Body)
+	if err == nil {
+		err = r.setPayload(v)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error parsing CBOR payload: %+v", err)
+	}
+
+	return nil
+}
+
+// setPayload sets the payload to a decoded map.  Arrays are stored under the
+// "root" key, like JSON arrays.
+func (r *Request) setPayload(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		r.Payload = v
+	case []interface{}:
+		r.Payload = map[string]interface{}{"root": v}
+	default:
+		return fmt.Errorf("payload must be a map or an array, not %T", v)
+	}
+
+	return nil
+}
+
 func (r *Request) ParseHeaders(headers map[string][]string) {
 	r.Headers = make(map[string]interface{}, len(headers))
+	r.HeaderValues = headers
 
 	for k, v := range headers {
 		if len(v) > 0 {
@@ -82,6 +298,7 @@ func (r *Request) ParseHeaders(headers map[string][]string) {
 
 func (r *Request) ParseQuery(query map[string][]string) {
 	r.Query = make(map[string]interface{}, len(query))
+	r.QueryValues = query
 
 	for k, v := range query {
 		if len(v) > 0 {
@@ -97,16 +314,80 @@ func (r *Request) ParseFormPayload() error {
 	}
 
 	r.Payload = make(map[string]interface{}, len(fd))
+	r.ParseFormValues(fd)
+
+	return nil
+}
 
-	for k, v := range fd {
+// ParseFormValues sets the payload to the first value of each form field.
+// All values are kept in FormValues.
+func (r *Request) ParseFormValues(values map[string][]string) {
+	if r.Payload == nil {
+		r.Payload = make(map[string]interface{}, len(values))
+	}
+
+	if r.FormValues == nil {
+		r.FormValues = make(map[string][]string, len(values))
+	}
+
+	for k, v := range values {
 		if len(v) > 0 {
 			r.Payload[k] = v[0]
+			r.FormValues[k] = v
 		}
 	}
+}
+
+// values returns all values of the repeated fields of an argument source, or
+// nil if the source has none.
+func (r *Request) values(source string) map[string][]string {
+	switch source {
+	case SourceHeader:
+		return r.HeaderValues
+	case SourceQuery, SourceQueryAlias:
+		return r.QueryValues
+	case SourcePayload:
+		return r.FormValues
+	}
 
 	return nil
 }
 
+// lookupValues resolves a reference to repeated values: "name.N" is the value
+// at index N and "name.*" is the list of all values.  The last return value
+// reports whether key refers to a repeated field.
+func lookupValues(values map[string][]string, key string) (interface{}, bool, error) {
+	i := strings.LastIndexByte(key, '.')
+	if i == -1 {
+		return nil, false, nil
+	}
+
+	v, ok := values[key[:i]]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if key[i+1:] == "*" {
+		l := make([]interface{}, len(v))
+		for i := range v {
+			l[i] = v[i]
+		}
+
+		return l, true, nil
+	}
+
+	index, err := strconv.ParseUint(key[i+1:], 10, 64)
+	if err != nil {
+		return nil, false, nil
+	}
+
+	if index >= uint64(len(v)) {
+		return nil, true, &ParameterNodeError{key}
+	}
+
+	return v[index], true, nil
+}
+
 func (r *Request) ParseXMLPayload() error {
 	var err error
 
@@ -0,0 +1,57 @@
+package hook
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return b
+}
+
+func TestRequestParsePayload(t *testing.T) {
+	object := map[string]interface{}{
+		"ref":     "refs/heads/main",
+		"size":    json.Number("3"),
+		"commits": []interface{}{map[string]interface{}{"id": "abc"}},
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		parse   func(*Request) error
+		body    string
+		payload map[string]interface{}
+		ok      bool
+	}{
+		{"json", (*Request).ParseJSONPayload, `{"ref": "refs/heads/main", "size": 3, "commits": [{"id": "abc"}]}`, object, true},
+		{"json array", (*Request).ParseJSONPayload, `[1, 2]`, map[string]interface{}{"root": []interface{}{json.Number("1"), json.Number("2")}}, true},
+		{"yaml", (*Request).ParseYAMLPayload, "ref: refs/heads/main\nsize: 3\ncommits:\n- id: abc\n", object, true},
+		{"yaml array", (*Request).ParseYAMLPayload, "- a\n- b\n", map[string]interface{}{"root": []interface{}{"a", "b"}}, true},
+		{"ndjson", (*Request).ParseNDJSONPayload, "{\"id\": 1}\n\n{\"id\": 2}\n", map[string]interface{}{"root": []interface{}{map[string]interface{}{"id": json.Number("1")}, map[string]interface{}{"id": json.Number("2")}}}, true},
+		{"empty ndjson", (*Request).ParseNDJSONPayload, "", map[string]interface{}{"root": []interface{}{}}, true},
+		// {"ref": "refs/heads/main", "size": 3, "commits": [{"id": "abc"}]}
+		{"msgpack", (*Request).ParseMsgPackPayload, string(mustDecodeHex("83a3726566af726566732f68656164732f6d61696ea473697a6503a7636f6d6d6974739181a26964a3616263")), object, true},
+		{"cbor", (*Request).ParseCBORPayload, string(mustDecodeHex("a3637265666f726566732f68656164732f6d61696e6473697a650367636f6d6d69747381a162696463616263")), object, true},
+		// failures
+		{"invalid json", (*Request).ParseJSONPayload, `{"ref":`, nil, false},
+		{"invalid yaml", (*Request).ParseYAMLPayload, "ref: [", nil, false},
+		{"yaml scalar", (*Request).ParseYAMLPayload, "ref", nil, false},
+		{"invalid ndjson", (*Request).ParseNDJSONPayload, "{\"id\": 1}\n{\"id\":\n", nil, false},
+		{"msgpack scalar", (*Request).ParseMsgPackPayload, "\x01", nil, false},
+		{"invalid cbor", (*Request).ParseCBORPayload, "\xff", nil, false},
+	} {
+		r := &Request{Body: []byte(tt.body)}
+
+		err := tt.parse(r)
+		if (err == nil) != tt.ok || (tt.ok && !reflect.DeepEqual(r.Payload, tt.payload)) {
+			t.Errorf("%s failed:\nexpected {payload:%#v, ok:%v}\ngot {payload:%#v, err:%v}", tt.desc, tt.payload, tt.ok, r.Payload, err)
+		}
+	}
+}
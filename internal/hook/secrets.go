@@ -0,0 +1,161 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Secret is a signature secret of a match rule.  The secret is either given
+// inline or read from a file, and is no longer accepted after the optional
+// NotAfter time.
+type Secret struct {
+	Value    string `json:"value,omitempty"`
+	File     string `json:"file,omitempty"`
+	NotAfter string `json:"not-after,omitempty"`
+}
+
+// secretFiles caches the secrets read from secret files.
+var secretFiles = newFileCache(func(data []byte) (interface{}, error) {
+	return ParseSecrets(data), nil
+})
+
+// ParseSecrets parses the contents of a secret file: one secret per line.
+// Surrounding whitespace and empty lines are ignored, so the file may hold the
+// current and the next secret during a rotation.
+func ParseSecrets(data []byte) []string {
+	var secrets []string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			secrets = append(secrets, line)
+		}
+	}
+
+	return secrets
+}
+
+// usesSecret reports whether the match rule type is validated with a secret.
+func (r MatchRule) usesSecret() bool {
+	switch r.Type {
+	case MatchHMACSHA1, MatchHMACSHA256, MatchHMACSHA512,
+		MatchHashSHA1, MatchHashSHA256, MatchHashSHA512,
+		ScalrSignature, MatchJWT, MatchHMACTimestamped, MatchHMAC, StandardWebhooks:
+		return true
+	}
+
+	return false
+}
+
+// notAfter parses the expiry time of the secret.
+func (s Secret) notAfter() (time.Time, error) {
+	if s.NotAfter == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s.NotAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid not-after time: %w", err)
+	}
+
+	return t, nil
+}
+
+// values returns the secret value, or the secrets of its file.
+func (s Secret) values() ([]string, error) {
+	switch {
+	case s.Value != "" && s.File != "":
+		return nil, errors.New("only one of value and file may be given")
+	case s.File != "":
+		return readSecretFile(s.File)
+	case s.Value != "":
+		return []string{s.Value}, nil
+	}
+
+	return nil, errors.New("value or file is required")
+}
+
+// readSecretFile returns the secrets of a secret file.
+func readSecretFile(file string) ([]string, error) {
+	v, err := secretFiles.Get(file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading secret file: %w", err)
+	}
+
+	return v.([]string), nil
+}
+
+// secrets returns the secrets of the match rule that are active at the given
+// time: the inline secret, the secrets of the secret file and the unexpired
+// entries of the secrets list.
+func (r MatchRule) secrets(now time.Time) ([]string, error) {
+	var secrets []string
+
+	if r.Secret != "" {
+		secrets = append(secrets, r.Secret)
+	}
+
+	if r.SecretFile != "" {
+		s, err := readSecretFile(r.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, s...)
+	}
+
+	for i, s := range r.Secrets {
+		notAfter, err := s.notAfter()
+		if err != nil {
+			return nil, fmt.Errorf("secrets[%d]: %w", i, err)
+		}
+
+		if !notAfter.IsZero() && now.After(notAfter) {
+			continue
+		}
+
+		values, err := s.values()
+		if err != nil {
+			return nil, fmt.Errorf("secrets[%d]: %w", i, err)
+		}
+
+		secrets = append(secrets, values...)
+	}
+
+	return secrets, nil
+}
+
+// evaluateWithSecrets evaluates a signature match rule that has a secret file
+// or a list of secrets.  The rule matches if the signature is valid for any of
+// the active secrets; otherwise the result for the last secret is returned.
+func (r MatchRule) evaluateWithSecrets(req *Request) (bool, error) {
+	secrets, err := r.secrets(req.now())
+	if err != nil {
+		return false, err
+	}
+
+	if len(secrets) == 0 {
+		return false, errors.New("no active secret defined")
+	}
+
+	rule := r
+	rule.SecretFile, rule.Secrets = "", nil
+
+	var ok bool
+
+	for _, s := range secrets {
+		rule.Secret = s
+
+		ok, err = rule.Evaluate(req)
+		if ok {
+			return true, err
+		}
+	}
+
+	return ok, err
+}
@@ -0,0 +1,160 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchRuleSecrets(t *testing.T) {
+	body := []byte(`{"a": "z"}`)
+
+	sign := func(secret string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("file-secret\n\nnext-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	rule := MatchRule{
+		Type:       MatchHMACSHA256,
+		Secret:     "inline-secret",
+		SecretFile: file,
+		Secrets: []Secret{
+			{Value: "old-secret", NotAfter: "2024-05-31T00:00:00Z"},
+			{Value: "current-secret", NotAfter: "2024-07-01T00:00:00Z"},
+			{Value: "new-secret"},
+		},
+		Parameter: Argument{Source: SourceHeader, Name: "X-Signature"},
+	}
+
+	for _, tt := range []struct {
+		secret string
+		ok     bool
+	}{
+		{"inline-secret", true},
+		{"file-secret", true},
+		{"next-secret", true},
+		{"current-secret", true},
+		{"new-secret", true},
+		{"old-secret", false},
+		{"unknown-secret", false},
+	} {
+		req := &Request{
+			Body:    body,
+			Headers: map[string]interface{}{"X-Signature": sign(tt.secret)},
+			Clock:   func() time.Time { return now },
+		}
+
+		ok, err := rule.Evaluate(req)
+		if ok != tt.ok || (err == nil) != tt.ok {
+			t.Errorf("secret %s failed:\nexpected ok: %v\ngot ok: %v, err: %v", tt.secret, tt.ok, ok, err)
+		}
+
+		if !ok && !IsSignatureError(err) {
+			t.Errorf("secret %s failed:\nexpected signature error\ngot %v", tt.secret, err)
+		}
+	}
+
+	// Rotate the secret file.
+	if err := os.WriteFile(file, []byte("rotated-secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for secret, want := range map[string]bool{"rotated-secret": true, "file-secret": false} {
+		req := &Request{
+			Body:    body,
+			Headers: map[string]interface{}{"X-Signature": sign(secret)},
+			Clock:   func() time.Time { return now },
+		}
+
+		if ok, _ := rule.Evaluate(req); ok != want {
+			t.Errorf("rotated secret file failed for %s:\nexpected %v\ngot %v", secret, want, ok)
+		}
+	}
+}
+
+func TestMatchRuleSecretsExpired(t *testing.T) {
+	rule := MatchRule{
+		Type:      MatchHMACSHA256,
+		Secrets:   []Secret{{Value: "old-secret", NotAfter: "2024-05-31T00:00:00Z"}},
+		Parameter: Argument{Source: SourceHeader, Name: "X-Signature"},
+	}
+
+	req := &Request{
+		Headers: map[string]interface{}{"X-Signature": "sha256=00"},
+		Clock:   func() time.Time { return time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC) },
+	}
+
+	if ok, err := rule.Evaluate(req); ok || err == nil || IsSignatureError(err) {
+		t.Errorf("expected configuration error without active secrets, got ok: %v, err: %v", ok, err)
+	}
+}
+
+func TestSecretsLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hooks.yaml")
+
+	err := os.WriteFile(file, []byte(`
+- id: secrets
+  trigger-rule:
+    and:
+    - match:
+        type: payload-hmac-sha256
+        secret-file: /nonexistent/secret
+        parameter:
+          source: header
+          name: X-Signature
+    - match:
+        type: payload-hmac-sha256
+        secrets:
+        - value: a
+          not-after: tomorrow
+        - value: b
+          file: /etc/b
+        - not-after: "2024-05-31T00:00:00Z"
+        parameter:
+          source: header
+          name: X-Signature
+    - match:
+        type: value
+        value: x
+        secrets:
+        - value: a
+        parameter:
+          source: header
+          name: X-Value
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hooks Hooks
+
+	err = hooks.LoadFromFile(file, false)
+	if err == nil {
+		t.Fatal("expected errors loading hooks with invalid secrets")
+	}
+
+	for _, s := range []string{
+		"trigger-rule.and[0].match.secret-file: error loading secret file",
+		"trigger-rule.and[1].match.secrets[0].not-after: invalid not-after time",
+		"trigger-rule.and[1].match.secrets[1]: only one of value and file may be given",
+		"trigger-rule.and[1].match.secrets[2]: value or file is required",
+		`trigger-rule.and[2].match: match type "value" does not use secrets`,
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected error containing %q, got:\n%v", s, err)
+		}
+	}
+}
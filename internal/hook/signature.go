@@ -0,0 +1,454 @@
+package hook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Constants for the signature encodings
+const (
+	EncodingHex       string = "hex"
+	EncodingBase64    string = "base64"
+	EncodingBase64URL string = "base64url"
+)
+
+// DecodeSignature decodes a signature using the given encoding, which
+// defaults to hex.  Padding is optional for the base64 encodings.
+func DecodeSignature(signature, encoding string) ([]byte, error) {
+	switch encoding {
+	case "", EncodingHex:
+		return hex.DecodeString(signature)
+	case EncodingBase64:
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(signature, "="))
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(signature, "="))
+	}
+
+	return nil, fmt.Errorf("unsupported signature encoding %q", encoding)
+}
+
+// ParsePublicKeys parses public keys given inline.  The value can either be
+// PEM data or, for Ed25519, a raw key encoded in hex or base64.
+func ParsePublicKeys(s string) ([]crypto.PublicKey, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.HasPrefix(s, "-----BEGIN") {
+		return ParsePublicKeysPEM([]byte(s))
+	}
+
+	if b, err := hex.DecodeString(s); err == nil && len(b) == ed25519.PublicKeySize {
+		return []crypto.PublicKey{ed25519.PublicKey(b)}, nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == ed25519.PublicKeySize {
+		return []crypto.PublicKey{ed25519.PublicKey(b)}, nil
+	}
+
+	return nil, errors.New("invalid public key: must be PEM data or a hex or base64 encoded Ed25519 key")
+}
+
+// CheckPublicKeySignature verifies that any of the signatures is a valid
+// signature of the payload made with any of the given keys, using the
+// algorithm of the given match rule type.
+func CheckPublicKeySignature(typ string, payload []byte, keys []crypto.PublicKey, signatures []string, encoding string) error {
+	if len(keys) == 0 {
+		return errors.New("signature validation public key can not be empty")
+	}
+
+	sum := sha256.Sum256(payload)
+
+	for _, signature := range signatures {
+		sig, err := DecodeSignature(signature, encoding)
+		if err != nil {
+			continue
+		}
+
+		for _, key := range keys {
+			switch k := key.(type) {
+			case ed25519.PublicKey:
+				if typ == MatchEd25519 && ed25519.Verify(k, payload, sig) {
+					return nil
+				}
+
+			case *rsa.PublicKey:
+				if typ == MatchRSASHA256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) == nil {
+					return nil
+				}
+
+			case *ecdsa.PublicKey:
+				if typ == MatchECDSASHA256 && verifyECDSA(k, sum[:], sig) {
+					return nil
+				}
+			}
+		}
+	}
+
+	e := &SignatureError{Signatures: signatures}
+	if len(payload) == 0 {
+		e.emptyPayload = true
+	}
+
+	return e
+}
+
+// verifyECDSA verifies an ECDSA signature in either ASN.1 DER or the
+// fixed-size R || S encoding.
+func verifyECDSA(key *ecdsa.PublicKey, hash, sig []byte) bool {
+	size := (key.Curve.Params().BitSize + 7) / 8
+
+	if len(sig) == 2*size {
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+
+		if ecdsa.Verify(key, hash, r, s) {
+			return true
+		}
+	}
+
+	return ecdsa.VerifyASN1(key, hash, sig)
+}
+
+// publicKeys returns the public keys configured for the match rule.
+func (r MatchRule) publicKeys() ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+
+	if r.PublicKey != "" {
+		k, err := ParsePublicKeys(r.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k...)
+	}
+
+	if r.PublicKeyFile != "" {
+		v, err := publicKeyFiles.Get(r.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading public key file: %w", err)
+		}
+
+		keys = append(keys, v.([]crypto.PublicKey)...)
+	}
+
+	return keys, nil
+}
+
+// checkPublicKeySignature evaluates the payload-ed25519, payload-rsa-sha256
+// and payload-ecdsa-sha256 match rules.
+func (r MatchRule) checkPublicKeySignature(req *Request, signature string) (bool, error) {
+	keys, err := r.publicKeys()
+	if err != nil {
+		return false, err
+	}
+
+	payload := req.Body
+
+	if r.Timestamp != nil {
+		ts, err := r.Timestamp.Get(req)
+		if err != nil {
+			return false, err
+		}
+
+		payload = append([]byte(ts), req.Body...)
+	}
+
+	err = CheckPublicKeySignature(r.Type, payload, keys, ExtractSignatures(signature, ""), r.Encoding)
+
+	return err == nil, err
+}
+
+// hmacHashes maps the supported HMAC algorithm names to hash functions.
+var hmacHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// hmacHash returns the hash function for the given algorithm, which defaults
+// to sha256.
+func hmacHash(algorithm string) (func() hash.Hash, error) {
+	if algorithm == "" {
+		return sha256.New, nil
+	}
+
+	h, ok := hmacHashes[strings.ToLower(algorithm)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported HMAC algorithm %q", algorithm)
+	}
+
+	return h, nil
+}
+
+// DefaultSignatureTolerance is the maximum age of a timestamped signature if
+// the match rule does not specify a tolerance.
+const DefaultSignatureTolerance = 5 * time.Minute
+
+// DefaultTimestampedSignedContent is the template of the signed content of a
+// timestamped signature if the match rule does not specify one.
+const DefaultTimestampedSignedContent = "{{.Timestamp}}.{{.Body}}"
+
+// signedContentData is the data available to signed-content templates.
+type signedContentData struct {
+	req *Request
+
+	// Timestamp is the timestamp of a timestamped signature.
+	Timestamp string
+
+	// Body is the raw request body.
+	Body string
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// URL is the absolute URL of the request, reconstructed from the Host
+	// header and the request URI.
+	URL string
+
+	// RequestURI is the path and query of the request URL.
+	RequestURI string
+
+	// Path is the path of the request URL.
+	Path string
+
+	// RawQuery is the encoded query of the request URL, without the "?".
+	RawQuery string
+}
+
+// newSignedContentData returns the signed-content template data for req.
+func newSignedContentData(req *Request, timestamp string) *signedContentData {
+	d := &signedContentData{
+		req:       req,
+		Timestamp: timestamp,
+		Body:      string(req.Body),
+	}
+
+	if r := req.RawRequest; r != nil {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+
+		d.Method = r.Method
+		d.URL = scheme + "://" + r.Host + r.URL.RequestURI()
+		d.RequestURI = r.URL.RequestURI()
+		d.Path = r.URL.Path
+		d.RawQuery = r.URL.RawQuery
+	}
+
+	return d
+}
+
+// Header returns the value of the named header, or the empty string if it is
+// not present.
+func (d *signedContentData) Header(name string) string {
+	v, _ := (&Argument{Source: SourceHeader, Name: name}).Get(d.req)
+	return v
+}
+
+// Query returns the value of the named query parameter, or the empty string
+// if it is not present.
+func (d *signedContentData) Query(name string) string {
+	v, _ := (&Argument{Source: SourceQuery, Name: name}).Get(d.req)
+	return v
+}
+
+// SortedParams returns the names and values of the URL-encoded form body,
+// concatenated in the order of their names, as signed by Twilio.
+func (d *signedContentData) SortedParams() (string, error) {
+	values, err := url.ParseQuery(d.Body)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var b strings.Builder
+
+	for _, name := range names {
+		for _, v := range values[name] {
+			b.WriteString(name)
+			b.WriteString(v)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// defaultTimestampedSignedContent is the parsed
+// DefaultTimestampedSignedContent.
+var defaultTimestampedSignedContent = template.Must(parseSignedContent(DefaultTimestampedSignedContent))
+
+// parseSignedContent parses a signed-content template.
+func parseSignedContent(text string) (*template.Template, error) {
+	tmpl, err := template.New("signed-content").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed-content template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// signedContent renders the signed-content template of the match rule, or
+// def if the rule does not specify one.
+func (r MatchRule) signedContent(def *template.Template, data *signedContentData) ([]byte, error) {
+	tmpl := def
+
+	if r.SignedContent != "" {
+		tmpl = r.signedContentTemplate
+		if tmpl == nil {
+			var err error
+
+			if tmpl, err = parseSignedContent(r.SignedContent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("error rendering signed-content template: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// tolerance returns the maximum age of a timestamped signature.
+func (r MatchRule) tolerance() (time.Duration, error) {
+	if r.Tolerance == "" {
+		return DefaultSignatureTolerance, nil
+	}
+
+	d, err := time.ParseDuration(r.Tolerance)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tolerance %q: %w", r.Tolerance, err)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("invalid tolerance %q: must not be negative", r.Tolerance)
+	}
+
+	return d, nil
+}
+
+// EncodeSignature encodes a signature using the given encoding, which
+// defaults to hex.
+func EncodeSignature(signature []byte, encoding string) (string, error) {
+	switch encoding {
+	case "", EncodingHex:
+		return hex.EncodeToString(signature), nil
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(signature), nil
+	case EncodingBase64URL:
+		return base64.RawURLEncoding.EncodeToString(signature), nil
+	}
+
+	return "", fmt.Errorf("unsupported signature encoding %q", encoding)
+}
+
+// checkTimestampedHMAC evaluates the payload-hmac-timestamped match rule.
+// The timestamp is read from the rule's timestamp parameter, or from the "t="
+// element of the signature value if none is given.
+func (r MatchRule) checkTimestampedHMAC(req *Request, signature string) (bool, error) {
+	if r.Secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	newHash, err := hmacHash(r.Algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	tolerance, err := r.tolerance()
+	if err != nil {
+		return false, err
+	}
+
+	var timestamp string
+
+	if r.Timestamp != nil {
+		timestamp, err = r.Timestamp.Get(req)
+		if err != nil {
+			return false, err
+		}
+	} else {
+		values := ExtractCommaSeparatedValues(signature, "t=")
+		if len(values) == 0 {
+			return false, &SignatureError{Signature: "missing timestamp"}
+		}
+
+		timestamp = values[0]
+	}
+
+	ts, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return false, &SignatureError{Signature: "invalid timestamp"}
+	}
+
+	if delta := req.now().Sub(time.Unix(ts, 0)); delta > tolerance || delta < -tolerance {
+		return false, &SignatureError{Signature: "outdated"}
+	}
+
+	content, err := r.signedContent(defaultTimestampedSignedContent, newSignedContentData(req, timestamp))
+	if err != nil {
+		return false, err
+	}
+
+	signatures := ExtractSignatures(signature, r.Prefix)
+
+	_, err = ValidateMAC(content, hmac.New(newHash, []byte(r.Secret)), signatures, r.Encoding)
+
+	return err == nil, err
+}
+
+// checkHMAC evaluates the payload-hmac match rule.  The signed content
+// defaults to the request body.
+func (r MatchRule) checkHMAC(req *Request, signature string) (bool, error) {
+	if r.Secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	newHash, err := hmacHash(r.Algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	content := req.Body
+
+	if r.SignedContent != "" {
+		content, err = r.signedContent(nil, newSignedContentData(req, ""))
+		if err != nil {
+			return false, err
+		}
+	}
+
+	signatures := ExtractSignatures(signature, r.Prefix)
+
+	_, err = ValidateMAC(content, hmac.New(newHash, []byte(r.Secret)), signatures, r.Encoding)
+
+	return err == nil, err
+}
@@ -0,0 +1,329 @@
+package hook
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDecodeSignature(t *testing.T) {
+	for _, tt := range []struct {
+		sig, encoding string
+		value         []byte
+		ok            bool
+	}{
+		{"fb00", "", []byte{0xfb, 0x00}, true},
+		{"fb00", "hex", []byte{0xfb, 0x00}, true},
+		{"+wA=", "base64", []byte{0xfb, 0x00}, true},
+		{"+wA", "base64", []byte{0xfb, 0x00}, true},
+		{"-wA", "base64url", []byte{0xfb, 0x00}, true},
+		// failures
+		{"zz", "hex", nil, false},
+		{"-wA", "base64", nil, false},
+		{"fb00", "base32", nil, false},
+	} {
+		value, err := DecodeSignature(tt.sig, tt.encoding)
+		if (err == nil) != tt.ok || string(value) != string(tt.value) {
+			t.Errorf("failed to decode %q as %q:\nexpected {value:%x, ok:%v}\ngot {value:%x, err:%v}", tt.sig, tt.encoding, tt.value, tt.ok, value, err)
+		}
+	}
+}
+
+func TestCheckPublicKeySignatureVector(t *testing.T) {
+	// RFC 8032, section 7.1, TEST 1.
+	keys, err := ParsePublicKeys("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig := "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+
+	if err := CheckPublicKeySignature(MatchEd25519, []byte{}, keys, []string{sig}, ""); err != nil {
+		t.Errorf("failed to verify RFC 8032 test vector: %s", err)
+	}
+}
+
+func TestCheckPublicKeySignature(t *testing.T) {
+	payload := []byte(`{"a": "z"}`)
+	sum := sha256.Sum256(payload)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edSig := ed25519.Sign(edKey, payload)
+
+	rsaSig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecSig, err := ecdsa.SignASN1(rand.Reader, ecKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, s, err := ecdsa.Sign(rand.Reader, ecKey, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecRawSig := append(leftPad(r.Bytes(), 32), leftPad(s.Bytes(), 32)...)
+
+	all := []crypto.PublicKey{edPub, &rsaKey.PublicKey, &ecKey.PublicKey}
+
+	for _, tt := range []struct {
+		desc     string
+		typ      string
+		payload  []byte
+		keys     []crypto.PublicKey
+		sigs     []string
+		encoding string
+		ok       bool
+	}{
+		{"ed25519", MatchEd25519, payload, all, []string{hex.EncodeToString(edSig)}, "", true},
+		{"rsa", MatchRSASHA256, payload, all, []string{base64.StdEncoding.EncodeToString(rsaSig)}, "base64", true},
+		{"ecdsa asn1", MatchECDSASHA256, payload, all, []string{hex.EncodeToString(ecSig)}, "hex", true},
+		{"ecdsa raw", MatchECDSASHA256, payload, all, []string{base64.RawURLEncoding.EncodeToString(ecRawSig)}, "base64url", true},
+		{"multiple signatures", MatchEd25519, payload, all, []string{"00", hex.EncodeToString(edSig)}, "", true},
+		// failures
+		{"wrong algorithm", MatchRSASHA256, payload, all, []string{hex.EncodeToString(edSig)}, "", false},
+		{"modified payload", MatchEd25519, []byte(`{"a": "y"}`), all, []string{hex.EncodeToString(edSig)}, "", false},
+		{"wrong encoding", MatchEd25519, payload, all, []string{hex.EncodeToString(edSig)}, "base64", false},
+		{"no keys", MatchEd25519, payload, nil, []string{hex.EncodeToString(edSig)}, "", false},
+	} {
+		err := CheckPublicKeySignature(tt.typ, tt.payload, tt.keys, tt.sigs, tt.encoding)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s failed: expected ok: %v, got err: %v", tt.desc, tt.ok, err)
+		}
+	}
+}
+
+func TestPublicKeySignatureMatchRule(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"type": 1}`)
+	timestamp := "1700000000"
+	sig := hex.EncodeToString(ed25519.Sign(key, append([]byte(timestamp), body...)))
+
+	for _, tt := range []struct {
+		desc      string
+		publicKey string
+		headers   map[string]interface{}
+		ok        bool
+		sigErr    bool
+	}{
+		{"hex key", hex.EncodeToString(pub), map[string]interface{}{"X-Signature-Ed25519": sig, "X-Signature-Timestamp": timestamp}, true, false},
+		{"PEM key", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), map[string]interface{}{"X-Signature-Ed25519": sig, "X-Signature-Timestamp": timestamp}, true, false},
+		// failures
+		{"wrong timestamp", hex.EncodeToString(pub), map[string]interface{}{"X-Signature-Ed25519": sig, "X-Signature-Timestamp": "1700000001"}, false, true},
+		{"invalid key", "not-a-key", map[string]interface{}{"X-Signature-Ed25519": sig, "X-Signature-Timestamp": timestamp}, false, false},
+	} {
+		r := MatchRule{
+			Type:      "payload-ed25519",
+			PublicKey: tt.publicKey,
+			Parameter: Argument{Source: "header", Name: "X-Signature-Ed25519"},
+			Timestamp: &Argument{Source: "header", Name: "X-Signature-Timestamp"},
+		}
+
+		req := &Request{
+			Headers: tt.headers,
+			Body:    body,
+		}
+
+		ok, err := r.Evaluate(req)
+		if ok != tt.ok || (err != nil && IsSignatureError(err) != tt.sigErr) {
+			t.Errorf("%s failed:\nexpected ok: %v, signature error: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, tt.sigErr, ok, err)
+		}
+	}
+}
+
+func TestTimestampedHMACMatchRule(t *testing.T) {
+	// Example request from Slack's "Verifying requests from Slack" guide.
+	slackBody := []byte("token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c")
+	slackSecret := "8f742231b10e8888abcd99yyyzzz85a5"
+	slackSig := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+
+	slack := MatchRule{
+		Type:          "payload-hmac-timestamped",
+		Secret:        slackSecret,
+		Prefix:        "v0=",
+		SignedContent: "v0:{{.Timestamp}}:{{.Body}}",
+		Parameter:     Argument{Source: "header", Name: "X-Slack-Signature"},
+		Timestamp:     &Argument{Source: "header", Name: "X-Slack-Request-Timestamp"},
+	}
+
+	stripeBody := []byte(`{"id": "evt_1"}`)
+	stripeSecret := "whsec_test"
+	stripeSig := func(secret, ts string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "." + string(stripeBody)))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	stripe := MatchRule{
+		Type:      "payload-hmac-timestamped",
+		Secret:    stripeSecret,
+		Prefix:    "v1=",
+		Tolerance: "10m",
+		Parameter: Argument{Source: "header", Name: "Stripe-Signature"},
+	}
+
+	withSecret := func(r MatchRule, secret string) MatchRule {
+		r.Secret = secret
+		return r
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		rule    MatchRule
+		headers map[string]interface{}
+		body    []byte
+		now     int64
+		ok      bool
+		sigErr  bool
+	}{
+		{"slack", slack, map[string]interface{}{"X-Slack-Signature": slackSig, "X-Slack-Request-Timestamp": "1531420618"}, slackBody, 1531420618, true, false},
+		{"slack within tolerance", slack, map[string]interface{}{"X-Slack-Signature": slackSig, "X-Slack-Request-Timestamp": "1531420618"}, slackBody, 1531420618 + 299, true, false},
+		{"stripe", stripe, map[string]interface{}{"Stripe-Signature": "t=1700000000,v1=" + stripeSig(stripeSecret, "1700000000")}, stripeBody, 1700000000, true, false},
+		{"stripe rotated secret", stripe, map[string]interface{}{"Stripe-Signature": "t=1700000000,v1=" + stripeSig("whsec_old", "1700000000") + ",v1=" + stripeSig(stripeSecret, "1700000000") + ",v0=00"}, stripeBody, 1700000000 + 500, true, false},
+		// failures
+		{"slack outdated", slack, map[string]interface{}{"X-Slack-Signature": slackSig, "X-Slack-Request-Timestamp": "1531420618"}, slackBody, 1531420618 + 301, false, true},
+		{"slack from the future", slack, map[string]interface{}{"X-Slack-Signature": slackSig, "X-Slack-Request-Timestamp": "1531420618"}, slackBody, 1531420618 - 301, false, true},
+		{"slack wrong secret", withSecret(slack, "other"), map[string]interface{}{"X-Slack-Signature": slackSig, "X-Slack-Request-Timestamp": "1531420618"}, slackBody, 1531420618, false, true},
+		{"stripe modified timestamp", stripe, map[string]interface{}{"Stripe-Signature": "t=1700000001,v1=" + stripeSig(stripeSecret, "1700000000")}, stripeBody, 1700000000, false, true},
+		{"stripe missing timestamp", stripe, map[string]interface{}{"Stripe-Signature": "v1=" + stripeSig(stripeSecret, "1700000000")}, stripeBody, 1700000000, false, true},
+		{"stripe invalid timestamp", stripe, map[string]interface{}{"Stripe-Signature": "t=yesterday,v1=" + stripeSig(stripeSecret, "1700000000")}, stripeBody, 1700000000, false, true},
+		// errors
+		{"missing secret", withSecret(stripe, ""), map[string]interface{}{"Stripe-Signature": "t=1700000000,v1=" + stripeSig(stripeSecret, "1700000000")}, stripeBody, 1700000000, false, false},
+	} {
+		now := time.Unix(tt.now, 0)
+		req := &Request{
+			Headers: tt.headers,
+			Body:    tt.body,
+			Clock:   func() time.Time { return now },
+		}
+
+		ok, err := tt.rule.Evaluate(req)
+		if ok != tt.ok || (err != nil && IsSignatureError(err) != tt.sigErr) || (err == nil && !tt.ok) {
+			t.Errorf("%s failed:\nexpected ok: %v, signature error: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, tt.sigErr, ok, err)
+		}
+	}
+}
+
+func TestHMACMatchRule(t *testing.T) {
+	body := []byte(`{"id": 1}`)
+
+	sign := func(secret, content string, encode func([]byte) string) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(content))
+		return encode(mac.Sum(nil))
+	}
+
+	shopify := MatchRule{
+		Type:      "payload-hmac",
+		Secret:    "secret",
+		Encoding:  "base64",
+		Parameter: Argument{Source: "header", Name: "X-Shopify-Hmac-Sha256"},
+	}
+
+	// Example request from Twilio's "Webhooks Security" guide.
+	twilioBody := []byte("To=%2B18005551212&Digits=1234&From=%2B12349013030&Caller=%2B12349013030&CallSid=CA1234567890ABCDE")
+
+	twilio := MatchRule{
+		Type:          "payload-hmac",
+		Secret:        "12345",
+		Algorithm:     "sha1",
+		Encoding:      "base64",
+		SignedContent: "{{.URL}}{{.SortedParams}}",
+		Parameter:     Argument{Source: "header", Name: "X-Twilio-Signature"},
+	}
+
+	headers := MatchRule{
+		Type:          "payload-hmac",
+		Secret:        "secret",
+		Prefix:        "sha256=",
+		SignedContent: `{{.Method}} {{.RequestURI}} {{.Header "X-Delivery"}} {{.Query "token"}}:{{.Body}}`,
+		Parameter:     Argument{Source: "header", Name: "X-Signature"},
+	}
+
+	with := func(r MatchRule, f func(*MatchRule)) MatchRule {
+		f(&r)
+		return r
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		rule    MatchRule
+		method  string
+		target  string
+		headers map[string]interface{}
+		query   map[string]interface{}
+		body    []byte
+		ok      bool
+		sigErr  bool
+	}{
+		{"shopify", shopify, "POST", "/hooks/shopify", map[string]interface{}{"X-Shopify-Hmac-Sha256": sign("secret", string(body), base64.StdEncoding.EncodeToString)}, nil, body, true, false},
+		{"twilio", twilio, "POST", "https://mycompany.com/myapp.php?foo=1&bar=2", map[string]interface{}{"X-Twilio-Signature": "0/KCTR6DLpKmkAf8muzZqo1nDgQ="}, nil, twilioBody, true, false},
+		{"headers and query", headers, "POST", "/hooks/h?token=abc", map[string]interface{}{"X-Delivery": "42", "X-Signature": "sha256=" + sign("secret", `POST /hooks/h?token=abc 42 abc:{"id": 1}`, hex.EncodeToString)}, map[string]interface{}{"token": "abc"}, body, true, false},
+		{"base64url sha512", with(shopify, func(r *MatchRule) { r.Algorithm = "sha512"; r.Encoding = "base64url" }), "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": func() string {
+			mac := hmac.New(sha512.New, []byte("secret"))
+			mac.Write(body)
+			return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+		}()}, nil, body, true, false},
+		// failures
+		{"shopify hex signature", shopify, "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": sign("secret", string(body), hex.EncodeToString)}, nil, body, false, true},
+		{"shopify modified body", shopify, "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": sign("secret", string(body), base64.StdEncoding.EncodeToString)}, nil, []byte(`{"id": 2}`), false, true},
+		{"twilio wrong url", twilio, "POST", "https://mycompany.com/myapp.php?foo=1", map[string]interface{}{"X-Twilio-Signature": "0/KCTR6DLpKmkAf8muzZqo1nDgQ="}, nil, twilioBody, false, true},
+		{"missing header", headers, "POST", "/hooks/h?token=abc", map[string]interface{}{"X-Signature": "sha256=" + sign("secret", `POST /hooks/h?token=abc 42 abc:{"id": 1}`, hex.EncodeToString)}, map[string]interface{}{"token": "abc"}, body, false, true},
+		// errors
+		{"missing secret", with(shopify, func(r *MatchRule) { r.Secret = "" }), "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": "x"}, nil, body, false, false},
+		{"unsupported algorithm", with(shopify, func(r *MatchRule) { r.Algorithm = "md5" }), "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": "x"}, nil, body, false, false},
+		{"unsupported encoding", with(shopify, func(r *MatchRule) { r.Encoding = "base32" }), "POST", "/", map[string]interface{}{"X-Shopify-Hmac-Sha256": "x"}, nil, body, false, false},
+		{"invalid template", with(headers, func(r *MatchRule) { r.SignedContent = "{{.Body" }), "POST", "/", map[string]interface{}{"X-Signature": "x"}, nil, body, false, false},
+	} {
+		req := &Request{
+			Headers:    tt.headers,
+			Query:      tt.query,
+			Body:       tt.body,
+			RawRequest: httptest.NewRequest(tt.method, tt.target, bytes.NewReader(tt.body)),
+		}
+
+		ok, err := tt.rule.Evaluate(req)
+		if ok != tt.ok || (err != nil && IsSignatureError(err) != tt.sigErr) || (err == nil && !tt.ok) {
+			t.Errorf("%s failed:\nexpected ok: %v, signature error: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, tt.sigErr, ok, err)
+		}
+	}
+}
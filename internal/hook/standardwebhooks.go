@@ -0,0 +1,83 @@
+package hook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StandardWebhooksSecretPrefix is the prefix of Standard Webhooks secrets.
+const StandardWebhooksSecretPrefix = "whsec_"
+
+// standardWebhooksHeaders lists the names of the id, timestamp and signature
+// headers, as defined by the Standard Webhooks specification and as sent by
+// Svix.
+var standardWebhooksHeaders = [][3]string{
+	{"Webhook-Id", "Webhook-Timestamp", "Webhook-Signature"},
+	{"Svix-Id", "Svix-Timestamp", "Svix-Signature"},
+}
+
+// CheckStandardWebhooksSignature verifies the signature of a request sent
+// according to the Standard Webhooks specification.  The secret is the
+// base64-encoded key, optionally prefixed with "whsec_".  The signature header
+// may contain multiple space-separated signatures, of which only the "v1"
+// ones are checked.
+func CheckStandardWebhooksSignature(r *Request, secret string, tolerance time.Duration, now time.Time) (bool, error) {
+	if secret == "" {
+		return false, errors.New("signature validation secret can not be empty")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, StandardWebhooksSecretPrefix))
+	if err != nil {
+		return false, fmt.Errorf("invalid standard-webhooks secret: %w", err)
+	}
+
+	if r.Headers == nil {
+		return false, nil
+	}
+
+	var id, timestamp, signature string
+
+	for _, names := range standardWebhooksHeaders {
+		if v, ok := r.Headers[names[2]].(string); ok {
+			id, _ = r.Headers[names[0]].(string)
+			timestamp, _ = r.Headers[names[1]].(string)
+			signature = v
+			break
+		}
+	}
+
+	if id == "" || timestamp == "" || signature == "" {
+		return false, nil
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false, &SignatureError{Signature: "invalid timestamp"}
+	}
+
+	if delta := now.Sub(time.Unix(ts, 0)); delta > tolerance || delta < -tolerance {
+		return false, &SignatureError{Signature: "outdated"}
+	}
+
+	var signatures []string
+
+	for _, s := range strings.Fields(signature) {
+		if strings.HasPrefix(s, "v1,") {
+			signatures = append(signatures, strings.TrimPrefix(s, "v1,"))
+		}
+	}
+
+	payload := make([]byte, 0, len(id)+len(timestamp)+len(r.Body)+2)
+	payload = append(payload, id+"."+timestamp+"."...)
+	payload = append(payload, r.Body...)
+
+	_, err = ValidateMAC(payload, hmac.New(sha256.New, key), signatures, EncodingBase64)
+
+	return err == nil, err
+}
@@ -0,0 +1,78 @@
+package hook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStandardWebhooksSignature(t *testing.T) {
+	// Test vector from the Standard Webhooks reference libraries.
+	secret := "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"
+	id := "msg_p5jXN8AQM9LWM0D4loKWxJek"
+	timestamp := "1614265330"
+	body := []byte(`{"test": 2432232314}`)
+	sig := "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="
+
+	now := time.Unix(1614265330, 0)
+
+	for _, tt := range []struct {
+		desc    string
+		secret  string
+		headers map[string]interface{}
+		body    []byte
+		now     time.Time
+		ok      bool
+		sigErr  bool
+	}{
+		{"valid", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now, true, false},
+		{"secret without prefix", "MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw", map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now, true, false},
+		{"svix headers", secret, map[string]interface{}{"Svix-Id": id, "Svix-Timestamp": timestamp, "Svix-Signature": sig}, body, now, true, false},
+		{"multiple signatures", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": "v1,Ceo5qEr07ixe2NLpvHk3FH9bwy/WavXrAFQ/9tdO6mc= v2,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE= " + sig}, body, now, true, false},
+		{"within tolerance", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now.Add(4 * time.Minute), true, false},
+		// failures
+		{"missing headers", secret, map[string]interface{}{"Webhook-Signature": sig}, body, now, false, false},
+		{"modified body", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, []byte(`{"test": 2432232315}`), now, false, true},
+		{"modified id", secret, map[string]interface{}{"Webhook-Id": "msg_other", "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now, false, true},
+		{"wrong version", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": "v2,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="}, body, now, false, true},
+		{"outdated", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now.Add(6 * time.Minute), false, true},
+		{"from the future", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now.Add(-6 * time.Minute), false, true},
+		{"invalid timestamp", secret, map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": "yesterday", "Webhook-Signature": sig}, body, now, false, true},
+		// errors
+		{"missing secret", "", map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now, false, false},
+		{"invalid secret", "whsec_!", map[string]interface{}{"Webhook-Id": id, "Webhook-Timestamp": timestamp, "Webhook-Signature": sig}, body, now, false, false},
+	} {
+		req := &Request{
+			Headers: tt.headers,
+			Body:    tt.body,
+		}
+
+		ok, err := CheckStandardWebhooksSignature(req, tt.secret, DefaultSignatureTolerance, tt.now)
+		if ok != tt.ok || (err != nil && IsSignatureError(err) != tt.sigErr) || (err == nil && tt.sigErr) {
+			t.Errorf("%s failed:\nexpected ok: %v, signature error: %v\ngot ok: %v, err: %v", tt.desc, tt.ok, tt.sigErr, ok, err)
+		}
+	}
+}
+
+func TestStandardWebhooksMatchRule(t *testing.T) {
+	req := &Request{
+		Headers: map[string]interface{}{
+			"Webhook-Id":        "msg_p5jXN8AQM9LWM0D4loKWxJek",
+			"Webhook-Timestamp": "1614265330",
+			"Webhook-Signature": "v1,g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE=",
+		},
+		Body:  []byte(`{"test": 2432232314}`),
+		Clock: func() time.Time { return time.Unix(1614265330+600, 0) },
+	}
+
+	r := MatchRule{Type: "standard-webhooks", Secret: "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"}
+
+	if ok, err := r.Evaluate(req); ok || !IsSignatureError(err) {
+		t.Errorf("expected outdated signature error, got ok: %v, err: %v", ok, err)
+	}
+
+	r.Tolerance = "15m"
+
+	if ok, err := r.Evaluate(req); !ok || err != nil {
+		t.Errorf("failed to match standard-webhooks rule: ok: %v, err: %v", ok, err)
+	}
+}
@@ -0,0 +1,334 @@
+package hook
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sfToken is a token value of a structured field, as defined by RFC 8941.
+type sfToken string
+
+// sfParam is a parameter of a structured field item or inner list.
+type sfParam struct {
+	Name  string
+	Value interface{}
+}
+
+// sfParams is the ordered list of parameters of an item or inner list.
+type sfParams []sfParam
+
+// Get returns the value of the named parameter.
+func (p sfParams) Get(name string) (interface{}, bool) {
+	for _, param := range p {
+		if param.Name == name {
+			return param.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// sfItem is a structured field item: a bare item with parameters.  Value is
+// one of int64, string, sfToken, []byte or bool.
+type sfItem struct {
+	Value  interface{}
+	Params sfParams
+}
+
+// sfMember is a member of a structured field dictionary.  The value is either
+// an inner list (Items) or a single item (Item).  Raw holds the member value
+// as it appeared in the field.
+type sfMember struct {
+	Name   string
+	Item   *sfItem
+	Items  []sfItem
+	Params sfParams
+	Raw    string
+}
+
+// sfParser parses structured field values.
+type sfParser struct {
+	s   string
+	pos int
+}
+
+// parseSFDictionary parses a structured field dictionary.  Only the subset of
+// RFC 8941 needed for HTTP message signatures is supported: decimals are
+// rejected.
+func parseSFDictionary(s string) ([]sfMember, error) {
+	p := &sfParser{s: s}
+	p.skipSpace()
+
+	var members []sfMember
+
+	for !p.eof() {
+		name, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		m := sfMember{Name: name}
+		start := p.pos
+
+		if p.consume('=') {
+			start = p.pos
+
+			if p.peek() == '(' {
+				m.Items, err = p.parseInnerList()
+			} else {
+				var item sfItem
+				item, err = p.parseItem()
+				m.Item = &item
+			}
+		} else {
+			m.Item = &sfItem{Value: true}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if m.Items != nil || p.peek() == ';' {
+			m.Params, err = p.parseParams()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		m.Raw = p.s[start:p.pos]
+		members = append(members, m)
+
+		p.skipOWS()
+		if p.eof() {
+			break
+		}
+
+		if !p.consume(',') {
+			return nil, fmt.Errorf("expected ',' at offset %d", p.pos)
+		}
+
+		p.skipOWS()
+		if p.eof() {
+			return nil, errors.New("trailing ',' in dictionary")
+		}
+	}
+
+	return members, nil
+}
+
+func (p *sfParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *sfParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+
+	return p.s[p.pos]
+}
+
+func (p *sfParser) consume(c byte) bool {
+	if p.peek() == c && !p.eof() {
+		p.pos++
+		return true
+	}
+
+	return false
+}
+
+func (p *sfParser) skipSpace() {
+	for p.peek() == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// parseInnerList parses an inner list, including its parameters.
+func (p *sfParser) parseInnerList() ([]sfItem, error) {
+	if !p.consume('(') {
+		return nil, fmt.Errorf("expected '(' at offset %d", p.pos)
+	}
+
+	items := make([]sfItem, 0)
+
+	for {
+		p.skipSpace()
+
+		if p.consume(')') {
+			return items, nil
+		}
+
+		item, err := p.parseItem()
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+
+		if c := p.peek(); c != ' ' && c != ')' {
+			return nil, fmt.Errorf("expected ' ' or ')' at offset %d", p.pos)
+		}
+	}
+}
+
+// parseItem parses a bare item and its parameters.
+func (p *sfParser) parseItem() (sfItem, error) {
+	v, err := p.parseBareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+
+	params, err := p.parseParams()
+	if err != nil {
+		return sfItem{}, err
+	}
+
+	return sfItem{Value: v, Params: params}, nil
+}
+
+// parseParams parses a possibly empty list of parameters.
+func (p *sfParser) parseParams() (sfParams, error) {
+	var params sfParams
+
+	for p.consume(';') {
+		p.skipSpace()
+
+		name, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{} = true
+
+		if p.consume('=') {
+			v, err = p.parseBareItem()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		params = append(params, sfParam{Name: name, Value: v})
+	}
+
+	return params, nil
+}
+
+// parseKey parses a dictionary or parameter key.
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+
+	if c := p.peek(); !(c >= 'a' && c <= 'z') && c != '*' {
+		return "", fmt.Errorf("invalid key at offset %d", p.pos)
+	}
+
+	for !p.eof() {
+		c := p.peek()
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && !strings.ContainsRune("_-.*", rune(c)) {
+			break
+		}
+		p.pos++
+	}
+
+	return p.s[start:p.pos], nil
+}
+
+// parseBareItem parses an integer, string, token, byte sequence or boolean.
+func (p *sfParser) parseBareItem() (interface{}, error) {
+	c := p.peek()
+
+	switch {
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+
+		for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+			p.pos++
+		}
+
+		if p.peek() == '.' {
+			return nil, fmt.Errorf("unsupported decimal at offset %d", start)
+		}
+
+		return strconv.ParseInt(p.s[start:p.pos], 10, 64)
+
+	case c == '"':
+		p.pos++
+
+		var b strings.Builder
+
+		for !p.eof() {
+			c := p.s[p.pos]
+			p.pos++
+
+			switch {
+			case c == '\\':
+				if p.eof() || (p.s[p.pos] != '"' && p.s[p.pos] != '\\') {
+					return nil, fmt.Errorf("invalid escape at offset %d", p.pos)
+				}
+				b.WriteByte(p.s[p.pos])
+				p.pos++
+			case c == '"':
+				return b.String(), nil
+			case c < 0x20 || c > 0x7e:
+				return nil, fmt.Errorf("invalid character in string at offset %d", p.pos-1)
+			default:
+				b.WriteByte(c)
+			}
+		}
+
+		return nil, errors.New("unterminated string")
+
+	case c == ':':
+		p.pos++
+
+		end := strings.IndexByte(p.s[p.pos:], ':')
+		if end == -1 {
+			return nil, errors.New("unterminated byte sequence")
+		}
+
+		v, err := base64.StdEncoding.DecodeString(p.s[p.pos : p.pos+end])
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte sequence: %w", err)
+		}
+
+		p.pos += end + 1
+
+		return v, nil
+
+	case c == '?':
+		p.pos++
+
+		switch {
+		case p.consume('1'):
+			return true, nil
+		case p.consume('0'):
+			return false, nil
+		}
+
+		return nil, fmt.Errorf("invalid boolean at offset %d", p.pos)
+
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '*':
+		start := p.pos
+
+		for !p.eof() {
+			c := p.peek()
+			if c <= ' ' || c >= 0x7f || strings.ContainsRune("\"(),;<=>?@[\\]{}", rune(c)) {
+				break
+			}
+			p.pos++
+		}
+
+		return sfToken(p.s[start:p.pos]), nil
+	}
+
+	return nil, fmt.Errorf("invalid item at offset %d", p.pos)
+}
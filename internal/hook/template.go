@@ -0,0 +1,91 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// templateFuncs are the functions available to hook file, argument and
+// response message templates.
+var templateFuncs = template.FuncMap{
+	"cat":        cat,
+	"credential": credential,
+	"getenv":     getenv,
+}
+
+// parseRequestTemplate parses a template rendered against a Request.
+// Referencing a missing map key, such as a payload field the request does
+// not have, is an error.
+func parseRequestTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return tmpl, nil
+}
+
+// templateRequest is the read-only view of a Request that request templates
+// are rendered against, so that templates can not call the methods which
+// modify the request.
+type templateRequest struct {
+	ID             string
+	HookID         string
+	Payload        map[string]interface{}
+	Headers        map[string]interface{}
+	Query          map[string]interface{}
+	PathParameters map[string]interface{}
+	JWTClaims      map[string]interface{}
+
+	req *Request
+}
+
+// RequestValue returns the value of a request source key, such as "host".
+func (t *templateRequest) RequestValue(key string) (string, error) {
+	return t.req.RequestValue(key)
+}
+
+// templateData returns the view of the request used by request templates.
+func (r *Request) templateData() *templateRequest {
+	return &templateRequest{
+		ID:             r.ID,
+		HookID:         r.HookID,
+		Payload:        r.Payload,
+		Headers:        r.Headers,
+		Query:          r.Query,
+		PathParameters: r.PathParameters,
+		JWTClaims:      r.JWTClaims,
+		req:            r,
+	}
+}
+
+// renderTemplate renders tmpl, or the template text if tmpl is nil, against
+// the request.
+func (r *Request) renderTemplate(tmpl *template.Template, text string) (string, error) {
+	if tmpl == nil {
+		var err error
+
+		if tmpl, err = parseRequestTemplate("template", text); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, r.templateData()); err != nil {
+		return "", fmt.Errorf("error rendering template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// RenderResponseMessage returns the response message of the hook, rendered
+// against the request if ResponseMessageTemplate is set.
+func (h *Hook) RenderResponseMessage(r *Request) (string, error) {
+	if !h.ResponseMessageTemplate {
+		return h.ResponseMessage, nil
+	}
+
+	return r.renderTemplate(h.responseMessage, h.ResponseMessage)
+}
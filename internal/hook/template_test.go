@@ -0,0 +1,67 @@
+package hook
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestArgumentGetTemplate(t *testing.T) {
+	t.Setenv("WEBHOOK_TEST_ENV", "production")
+
+	r := httptest.NewRequest("POST", "http://example.com/hooks/deploy", nil)
+
+	req := &Request{
+		ID:         "abc",
+		HookID:     "deploy",
+		RawRequest: r,
+		Headers:    map[string]interface{}{"X-Delivery": "42"},
+		Payload: map[string]interface{}{
+			"repository": map[string]interface{}{"name": "webhook"},
+			"sha":        "abc123",
+		},
+	}
+
+	for _, tt := range []struct {
+		desc, template string
+		expected       string
+		ok             bool
+	}{
+		{"payload", "{{ .Payload.repository.name }}@{{ .Payload.sha }}", "webhook@abc123", true},
+		{"header", `{{ index .Headers "X-Delivery" }}`, "42", true},
+		{"request metadata", `{{ .HookID }} {{ .ID }} {{ .RequestValue "host" }}`, "deploy abc example.com", true},
+		{"function", `{{ getenv "WEBHOOK_TEST_ENV" }}`, "production", true},
+		// failures
+		{"missing key", "{{ .Payload.ref }}", "", false},
+		{"unsupported request key", `{{ .RequestValue "host-name" }}`, "", false},
+		{"invalid template", "{{ .Payload.sha", "", false},
+		{"request method", "{{ .ParseJSONPayload }}", "", false},
+		{"raw request", "{{ .RawRequest.Host }}", "", false},
+	} {
+		arg := Argument{Source: SourceTemplate, Name: tt.template}
+
+		value, err := arg.Get(req)
+		if (err == nil) != tt.ok || value != tt.expected {
+			t.Errorf("%s failed:\nexpected {value:%q, ok:%v}\ngot {value:%q, err:%v}", tt.desc, tt.expected, tt.ok, value, err)
+		}
+	}
+}
+
+func TestHookRenderResponseMessage(t *testing.T) {
+	req := &Request{Payload: map[string]interface{}{"sha": "abc123"}}
+
+	for _, tt := range []struct {
+		desc     string
+		hook     Hook
+		expected string
+		ok       bool
+	}{
+		{"plain", Hook{ResponseMessage: "Deploying {{ .Payload.sha }}"}, "Deploying {{ .Payload.sha }}", true},
+		{"template", Hook{ResponseMessage: "Deploying {{ .Payload.sha }}", ResponseMessageTemplate: true}, "Deploying abc123", true},
+		{"missing key", Hook{ResponseMessage: "Deploying {{ .Payload.ref }}", ResponseMessageTemplate: true}, "", false},
+	} {
+		msg, err := tt.hook.RenderResponseMessage(req)
+		if (err == nil) != tt.ok || msg != tt.expected {
+			t.Errorf("%s failed:\nexpected {msg:%q, ok:%v}\ngot {msg:%q, err:%v}", tt.desc, tt.expected, tt.ok, msg, err)
+		}
+	}
+}
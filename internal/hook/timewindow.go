@@ -0,0 +1,222 @@
+package hook
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow describes a recurring weekly window of time, with optional
+// blackout periods, used by the time-window match rule.
+type TimeWindow struct {
+	// Days is the list of weekdays (e.g. "mon" or "monday") on which the
+	// window is open.  An empty list means every day.
+	Days []string `json:"days,omitempty"`
+
+	// Hours is the list of time ranges in "15:04-15:04" format during which
+	// the window is open.  Ranges ending before they start wrap past
+	// midnight.  An empty list means the whole day.
+	Hours []string `json:"hours,omitempty"`
+
+	// Timezone is the IANA name of the location used to evaluate the window.
+	// Defaults to the server's local time zone.
+	Timezone string `json:"timezone,omitempty"`
+
+	// Blackout is the list of periods during which the window is closed,
+	// regardless of Days and Hours.
+	Blackout []Blackout `json:"blackout,omitempty"`
+
+	// compiled reports whether the fields below were set by compile.
+	compiled  bool
+	loc       *time.Location
+	days      [7]bool
+	hours     []hourRange
+	blackouts []timeRange
+}
+
+// Blackout is a closed period of time.  From and To are either dates in
+// "2006-01-02" format, in which case the whole day is included, or RFC 3339
+// timestamps.
+type Blackout struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// hourRange is a parsed entry of TimeWindow.Hours, in minutes since
+// midnight.
+type hourRange struct {
+	start, end int
+}
+
+// contains reports whether the minute of the day falls within the range.
+func (r hourRange) contains(minute int) bool {
+	if r.start <= r.end {
+		return minute >= r.start && minute < r.end
+	}
+
+	return minute >= r.start || minute < r.end
+}
+
+// timeRange is a parsed blackout period.
+type timeRange struct {
+	from, to time.Time
+}
+
+// compile parses the days, hours, blackout periods and timezone of the
+// window, so that they do not have to be parsed on every request.
+func (w *TimeWindow) compile() error {
+	loc := time.Local
+	if w.Timezone != "" {
+		var err error
+
+		loc, err = time.LoadLocation(w.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid time-window timezone %q: %w", w.Timezone, err)
+		}
+	}
+
+	var days [7]bool
+
+	for _, d := range w.Days {
+		wd, ok := weekdays[strings.ToLower(strings.TrimSpace(d))]
+		if !ok {
+			return fmt.Errorf("invalid time-window day %q", d)
+		}
+
+		days[wd] = true
+	}
+
+	hours := make([]hourRange, 0, len(w.Hours))
+
+	for _, h := range w.Hours {
+		start, end, err := parseHourRange(h)
+		if err != nil {
+			return err
+		}
+
+		hours = append(hours, hourRange{start, end})
+	}
+
+	blackouts := make([]timeRange, 0, len(w.Blackout))
+
+	for _, b := range w.Blackout {
+		from, err := parseBlackoutTime(b.From, loc, false)
+		if err != nil {
+			return err
+		}
+
+		to, err := parseBlackoutTime(b.To, loc, true)
+		if err != nil {
+			return err
+		}
+
+		blackouts = append(blackouts, timeRange{from, to})
+	}
+
+	w.loc, w.days, w.hours, w.blackouts = loc, days, hours, blackouts
+	w.compiled = true
+
+	return nil
+}
+
+// CheckTimeWindow reports whether the given time falls within the window.
+func CheckTimeWindow(now time.Time, w *TimeWindow) (bool, error) {
+	if w == nil {
+		return false, errors.New("time-window rule is missing the time-window definition")
+	}
+
+	if !w.compiled {
+		// Compile a copy, since the window may be shared between requests.
+		c := *w
+		if err := c.compile(); err != nil {
+			return false, err
+		}
+
+		w = &c
+	}
+
+	now = now.In(w.loc)
+
+	for _, b := range w.blackouts {
+		if !now.Before(b.from) && now.Before(b.to) {
+			return false, nil
+		}
+	}
+
+	if len(w.Days) != 0 && !w.days[now.Weekday()] {
+		return false, nil
+	}
+
+	if len(w.hours) == 0 {
+		return true, nil
+	}
+
+	minute := now.Hour()*60 + now.Minute()
+
+	for _, h := range w.hours {
+		if h.contains(minute) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseHourRange parses a "15:04-15:04" range into minutes since midnight.
+func parseHourRange(s string) (int, int, error) {
+	p := strings.SplitN(s, "-", 2)
+	if len(p) != 2 {
+		return 0, 0, fmt.Errorf("invalid time-window hours %q: must be in HH:MM-HH:MM format", s)
+	}
+
+	var minutes [2]int
+
+	for i := range p {
+		v := strings.TrimSpace(p[i])
+
+		// Allow "24:00" as the end of the day.
+		if i == 1 && v == "24:00" {
+			minutes[i] = 24 * 60
+			continue
+		}
+
+		t, err := time.Parse("15:04", v)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid time-window hours %q: %w", s, err)
+		}
+
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+
+	return minutes[0], minutes[1], nil
+}
+
+// parseBlackoutTime parses a blackout boundary.  Date-only values are
+// resolved to the start of the day, or to the start of the following day when
+// end is true, so that blackout dates are inclusive.
+func parseBlackoutTime(s string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time-window blackout time %q: must be a date or RFC 3339 timestamp", s)
+	}
+
+	return t, nil
+}
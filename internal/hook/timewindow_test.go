@@ -0,0 +1,85 @@
+package hook
+
+import (
+	"testing"
+	"time"
+)
+
+var checkTimeWindowTests = []struct {
+	desc   string
+	now    string
+	window *TimeWindow
+	ok     bool
+	err    bool
+}{
+	{"empty window", "2024-03-06T10:00:00Z", &TimeWindow{Timezone: "UTC"}, true, false},
+	{"business hours", "2024-03-06T10:00:00Z", &TimeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: []string{"09:00-17:00"}, Timezone: "UTC"}, true, false},
+	{"business hours, long day names", "2024-03-06T10:00:00Z", &TimeWindow{Days: []string{"Wednesday"}, Hours: []string{"09:00-17:00"}, Timezone: "UTC"}, true, false},
+	{"multiple hour ranges", "2024-03-06T14:30:00Z", &TimeWindow{Hours: []string{"09:00-12:00", "14:00-17:00"}, Timezone: "UTC"}, true, false},
+	{"range wrapping midnight", "2024-03-06T23:30:00Z", &TimeWindow{Hours: []string{"22:00-06:00"}, Timezone: "UTC"}, true, false},
+	{"range ending at midnight", "2024-03-06T23:59:00Z", &TimeWindow{Hours: []string{"18:00-24:00"}, Timezone: "UTC"}, true, false},
+	{"timezone conversion", "2024-03-06T08:30:00Z", &TimeWindow{Hours: []string{"09:00-17:00"}, Timezone: "Europe/Berlin"}, true, false},
+	{"outside blackout", "2024-12-19T10:00:00Z", &TimeWindow{Timezone: "UTC", Blackout: []Blackout{{From: "2024-12-20", To: "2025-01-02"}}}, true, false},
+	// failures
+	{"weekend", "2024-03-09T10:00:00Z", &TimeWindow{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Hours: []string{"09:00-17:00"}, Timezone: "UTC"}, false, false},
+	{"after hours", "2024-03-06T17:00:00Z", &TimeWindow{Days: []string{"wed"}, Hours: []string{"09:00-17:00"}, Timezone: "UTC"}, false, false},
+	{"outside range wrapping midnight", "2024-03-06T12:00:00Z", &TimeWindow{Hours: []string{"22:00-06:00"}, Timezone: "UTC"}, false, false},
+	{"timezone conversion", "2024-03-06T07:30:00Z", &TimeWindow{Hours: []string{"09:00-17:00"}, Timezone: "Europe/Berlin"}, false, false},
+	{"blackout first day", "2024-12-20T00:00:00Z", &TimeWindow{Timezone: "UTC", Blackout: []Blackout{{From: "2024-12-20", To: "2025-01-02"}}}, false, false},
+	{"blackout last day", "2025-01-02T23:59:00Z", &TimeWindow{Timezone: "UTC", Blackout: []Blackout{{From: "2024-12-20", To: "2025-01-02"}}}, false, false},
+	{"blackout timestamps", "2024-03-08T16:00:00Z", &TimeWindow{Timezone: "UTC", Blackout: []Blackout{{From: "2024-03-08T15:00:00Z", To: "2024-03-11T08:00:00Z"}}}, false, false},
+	// errors
+	{"missing window", "2024-03-06T10:00:00Z", nil, false, true},
+	{"invalid timezone", "2024-03-06T10:00:00Z", &TimeWindow{Timezone: "Nowhere/Special"}, false, true},
+	{"invalid day", "2024-03-06T10:00:00Z", &TimeWindow{Days: []string{"someday"}, Timezone: "UTC"}, false, true},
+	{"invalid hours", "2024-03-06T10:00:00Z", &TimeWindow{Hours: []string{"09:00"}, Timezone: "UTC"}, false, true},
+	{"invalid hours on a closed day", "2024-03-09T10:00:00Z", &TimeWindow{Days: []string{"mon"}, Hours: []string{"bogus"}, Timezone: "UTC"}, false, true},
+	{"invalid hours in blackout", "2024-12-24T10:00:00Z", &TimeWindow{Hours: []string{"24:00-06:00"}, Timezone: "UTC", Blackout: []Blackout{{From: "2024-12-20", To: "2025-01-02"}}}, false, true},
+	{"invalid hour", "2024-03-06T10:00:00Z", &TimeWindow{Hours: []string{"09:00-25:00"}, Timezone: "UTC"}, false, true},
+	{"invalid blackout", "2024-03-06T10:00:00Z", &TimeWindow{Timezone: "UTC", Blackout: []Blackout{{From: "yesterday", To: "2025-01-02"}}}, false, true},
+}
+
+func TestCheckTimeWindow(t *testing.T) {
+	for _, tt := range checkTimeWindowTests {
+		now, err := time.Parse(time.RFC3339, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ok, err := CheckTimeWindow(now, tt.window)
+		if ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("%s failed:\nexpected ok: %#v, err: %v\ngot ok: %#v, err: %v", tt.desc, tt.ok, tt.err, ok, err)
+		}
+	}
+}
+
+func TestTimeWindowMatchRule(t *testing.T) {
+	r := MatchRule{
+		Type: "time-window",
+		TimeWindow: &TimeWindow{
+			Days:     []string{"mon", "tue", "wed", "thu", "fri"},
+			Hours:    []string{"09:00-17:00"},
+			Timezone: "UTC",
+		},
+	}
+
+	for _, tt := range []struct {
+		now string
+		ok  bool
+	}{
+		{"2024-03-06T10:00:00Z", true},
+		{"2024-03-09T10:00:00Z", false},
+	} {
+		now, err := time.Parse(time.RFC3339, tt.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := &Request{Clock: func() time.Time { return now }}
+
+		ok, err := r.Evaluate(req)
+		if ok != tt.ok || err != nil {
+			t.Errorf("failed to match at %s:\nexpected ok: %#v\ngot ok: %#v, err: %v", tt.now, tt.ok, ok, err)
+		}
+	}
+}
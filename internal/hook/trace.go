@@ -0,0 +1,223 @@
+package hook
+
+import (
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// RedactedValue replaces secret values in rule evaluation traces.
+const RedactedValue = "[redacted]"
+
+// RuleTrace records the evaluation of a rule and its sub rules.
+type RuleTrace struct {
+	// Rule is the kind of rule: and, or, not, match or expr.
+	Rule string `json:"rule"`
+
+	// Type is the type of a match rule.
+	Type string `json:"type,omitempty"`
+
+	// Expr is the source of an expr rule.
+	Expr string `json:"expr,omitempty"`
+
+	// Parameter is the parameter of a match rule.
+	Parameter *Argument `json:"parameter,omitempty"`
+
+	// Value is the resolved value of the parameter, or RedactedValue if it
+	// contains a credential.
+	Value *string `json:"value,omitempty"`
+
+	// Result is the value the rule evaluated to.
+	Result bool `json:"result"`
+
+	// Error is the error returned by the rule, if any.
+	Error string `json:"error,omitempty"`
+
+	// Children are the traces of the sub rules.
+	Children []*RuleTrace `json:"children,omitempty"`
+
+	parent *RuleTrace
+}
+
+// String returns the trace as an indented tree, one rule per line.
+func (t *RuleTrace) String() string {
+	var b strings.Builder
+	t.write(&b, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (t *RuleTrace) write(b *strings.Builder, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString(t.Rule)
+
+	if t.Type != "" {
+		b.WriteString(" " + t.Type)
+	}
+
+	if t.Expr != "" {
+		b.WriteString(" " + t.Expr)
+	}
+
+	if t.Parameter != nil {
+		fmt.Fprintf(b, " %s %q", t.Parameter.Source, t.Parameter.Name)
+
+		if t.Value != nil {
+			fmt.Fprintf(b, " = %q", *t.Value)
+		}
+	}
+
+	fmt.Fprintf(b, ": %v", t.Result)
+
+	if t.Error != "" {
+		fmt.Fprintf(b, " (error: %s)", t.Error)
+	}
+
+	b.WriteString("\n")
+
+	for _, c := range t.Children {
+		c.write(b, depth+1)
+	}
+}
+
+// beginTrace records the start of the evaluation of the given rule, if the
+// request traces rules.  It returns the new trace node, or nil.
+func (r *Request) beginTrace(rules Rules) *RuleTrace {
+	if r == nil || !r.TraceRules {
+		return nil
+	}
+
+	t := &RuleTrace{parent: r.traceNode}
+
+	switch {
+	case rules.And != nil:
+		t.Rule = "and"
+	case rules.Or != nil:
+		t.Rule = "or"
+	case rules.Not != nil:
+		t.Rule = "not"
+	case rules.Match != nil:
+		t.Rule = "match"
+		t.Type = rules.Match.Type
+		if arg := rules.Match.Parameter; arg.Source != "" {
+			t.Parameter = &arg
+		}
+	case rules.Expr != nil:
+		t.Rule = "expr"
+		t.Expr = rules.Expr.traceSource()
+	default:
+		t.Rule = "empty"
+	}
+
+	if t.parent == nil {
+		r.RuleTrace = t
+	} else {
+		t.parent.Children = append(t.parent.Children, t)
+	}
+
+	r.traceNode = t
+
+	return t
+}
+
+// endTrace records the result of the rule traced by t.
+func (r *Request) endTrace(t *RuleTrace, ok bool, err error) {
+	if t == nil {
+		return
+	}
+
+	t.Result = ok
+	if err != nil {
+		t.Error = err.Error()
+	}
+
+	r.traceNode = t.parent
+}
+
+// traceValue records the value the parameter of the match rule being traced
+// resolved to.  Values which may contain a credential are redacted.
+func (r *Request) traceValue(m MatchRule, v string) {
+	if r == nil || r.traceNode == nil || r.traceNode.Rule != "match" || r.traceNode.Parameter == nil {
+		return
+	}
+
+	if m.redactTrace() {
+		v = RedactedValue
+	}
+
+	r.traceNode.Value = &v
+}
+
+// redactTrace reports whether the parameter value of the match rule must be
+// redacted in traces: if it is compared against a configured value, which
+// may be a shared secret, or if it may hold a credential.
+func (r MatchRule) redactTrace() bool {
+	switch r.Type {
+	case MatchValue, MatchBasicAuth, MatchBearerToken, MatchJWT:
+		return true
+	}
+
+	// Cookies may hold session tokens and templates may render credentials.
+	switch r.Parameter.Source {
+	case SourceCookie, SourceTemplate:
+		return true
+
+	case SourceHeader:
+		switch textproto.CanonicalMIMEHeaderKey(r.Parameter.Name) {
+		case "Authorization", "Proxy-Authorization", "Cookie":
+			return true
+		}
+	}
+
+	return false
+}
+
+// traceSource returns the expression source shown in traces.
+func (r *ExprRule) traceSource() string {
+	if r.redacted != "" {
+		return r.redacted
+	}
+
+	return redactExprSource(r.Source)
+}
+
+// redactExprSource returns the expression source with its string literals
+// redacted, since they may be secrets compared against the request.  Keys
+// of index expressions, such as "X-Event" in headers["X-Event"], are kept.
+func redactExprSource(src string) string {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return RedactedValue
+	}
+
+	var b strings.Builder
+
+	last := 0
+
+	for i, tok := range toks {
+		if tok.kind != exprStringLit {
+			continue
+		}
+
+		isKey := i >= 2 && toks[i-1].text == "[" && toks[i+1].text == "]"
+		if isKey {
+			switch prev := toks[i-2]; {
+			case prev.kind == exprIdent && prev.text != "in", prev.text == "]", prev.text == ")":
+			default:
+				isKey = false
+			}
+		}
+
+		if isKey {
+			continue
+		}
+
+		b.WriteString(src[last:tok.pos])
+		b.WriteString(strconv.Quote(RedactedValue))
+		last = tok.pos + len(tok.text)
+	}
+
+	b.WriteString(src[last:])
+
+	return b.String()
+}
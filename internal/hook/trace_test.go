@@ -0,0 +1,98 @@
+package hook
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestRedactExprSource(t *testing.T) {
+	for _, tt := range []struct {
+		src, expected string
+	}{
+		{`query.token == "s3cret"`, `query.token == "[redacted]"`},
+		{`headers["X-Token"] == 's3cret'`, `headers["X-Token"] == "[redacted]"`},
+		{`payload["a"]["b"] in ["push", "release"]`, `payload["a"]["b"] in ["[redacted]", "[redacted]"]`},
+		{`"s3cret" in ["s3cret"]`, `"[redacted]" in ["[redacted]"]`},
+		{`split(payload.ref, "/")[1] == "main"`, `split(payload.ref, "[redacted]")[1] == "[redacted]"`},
+		{`payload.size > 3`, `payload.size > 3`},
+		{`payload.ref == "unterminated`, `[redacted]`},
+	} {
+		if s := redactExprSource(tt.src); s != tt.expected {
+			t.Errorf("failed to redact %s:\nexpected %s\ngot %s", tt.src, tt.expected, s)
+		}
+	}
+}
+
+func TestRuleTrace(t *testing.T) {
+	expr, err := CompileExpr(`query.env == "production" && headers["X-Token"] != "s3cret"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules := Rules{And: &AndRule{
+		{Or: &OrRule{
+			{Match: &MatchRule{Type: "regex", Regex: "^refs/heads/main$", Parameter: Argument{Source: "payload", Name: "ref"}}},
+			{Match: &MatchRule{Type: "value", Value: "release", Parameter: Argument{Source: "payload", Name: "missing"}}},
+			{Match: &MatchRule{Type: "value", Value: "secret", Parameter: Argument{Source: "header", Name: "X-Gitlab-Token"}}},
+		}},
+		{Not: &NotRule{Match: &MatchRule{Type: "basic-auth", Credentials: []string{"alice:{SHA256}XohImNooBHFR0OVvjcYpJ3NgPQ1qq73WKhHvch0VQtg="}, Parameter: Argument{Source: "header", Name: "Authorization"}}}},
+		{Expr: expr},
+	}}
+
+	req := &Request{
+		Payload:    map[string]interface{}{"ref": "refs/heads/dev"},
+		Headers:    map[string]interface{}{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:password")), "X-Gitlab-Token": "guess"},
+		Query:      map[string]interface{}{"env": "production"},
+		TraceRules: true,
+	}
+
+	ok, err := rules.Evaluate(req)
+	if ok || err != nil {
+		t.Fatalf("unexpected result: ok: %v, err: %v", ok, err)
+	}
+
+	expected := `and: false
+  or: false
+    match regex payload "ref" = "refs/heads/dev": false
+    match value payload "missing": false (error: parameter node not found: missing)
+    match value header "X-Gitlab-Token" = "[redacted]": false`
+
+	if req.RuleTrace.String() != expected {
+		t.Errorf("failed to trace rules:\nexpected:\n%s\ngot:\n%s", expected, req.RuleTrace)
+	}
+
+	req.Payload["ref"] = "refs/heads/main"
+	req.RuleTrace = nil
+
+	ok, err = rules.Evaluate(req)
+	if ok || err != nil {
+		t.Fatalf("unexpected result: ok: %v, err: %v", ok, err)
+	}
+
+	expected = `and: false
+  or: true
+    match regex payload "ref" = "refs/heads/main": true
+  not: false
+    match basic-auth header "Authorization" = "[redacted]": true`
+
+	if req.RuleTrace.String() != expected {
+		t.Errorf("failed to trace rules:\nexpected:\n%s\ngot:\n%s", expected, req.RuleTrace)
+	}
+
+	req.Headers = nil
+	req.RuleTrace = nil
+
+	if ok, err = rules.Evaluate(req); !ok || err != nil {
+		t.Fatalf("unexpected result: ok: %v, err: %v", ok, err)
+	}
+
+	if last := (*req.RuleTrace.Children[len(req.RuleTrace.Children)-1]); last.Rule != "expr" || last.Expr != `query.env == "[redacted]" && headers["X-Token"] != "[redacted]"` || !last.Result {
+		t.Errorf("failed to trace expr rule: %+v", last)
+	}
+
+	req = &Request{Payload: map[string]interface{}{"ref": "refs/heads/main"}}
+
+	if _, err := rules.Evaluate(req); err != nil || req.RuleTrace != nil {
+		t.Errorf("rules should not be traced unless enabled, got: %v", req.RuleTrace)
+	}
+}
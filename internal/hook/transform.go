@@ -0,0 +1,150 @@
+package hook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Constants for the types of argument transforms.
+const (
+	TransformBase64Encode string = "base64-encode"
+	TransformBase64Decode string = "base64-decode"
+	TransformURLEncode    string = "url-encode"
+	TransformLower        string = "lower"
+	TransformUpper        string = "upper"
+	TransformTrim         string = "trim"
+	TransformRegexExtract string = "regex-extract"
+	TransformJSONEscape   string = "json-escape"
+	TransformSHA256       string = "sha256"
+)
+
+var transformTypes = []string{
+	TransformBase64Encode, TransformBase64Decode, TransformURLEncode,
+	TransformLower, TransformUpper, TransformTrim, TransformRegexExtract,
+	TransformJSONEscape, TransformSHA256,
+}
+
+// Transform is a step of the transform pipeline of an argument.  In hook
+// files, steps without options may be given as a plain string, such as
+// "lower".
+type Transform struct {
+	Type  string `json:"type,omitempty"`
+	Regex string `json:"regex,omitempty"`
+	Group int    `json:"group,omitempty"`
+
+	// regex is the compiled Regex.
+	regex *regexp.Regexp
+}
+
+// UnmarshalJSON implements json.Unmarshaler, accepting either a transform
+// object or the name of a transform type.
+func (t *Transform) UnmarshalJSON(data []byte) error {
+	var typ string
+	if err := json.Unmarshal(data, &typ); err == nil {
+		*t = Transform{Type: typ}
+		return nil
+	}
+
+	type transform Transform
+
+	return json.Unmarshal(data, (*transform)(t))
+}
+
+// compile checks the transform and compiles its regular expression.
+func (t *Transform) compile() error {
+	if !containsString(transformTypes, t.Type) {
+		return fmt.Errorf("unknown transform type %q", t.Type)
+	}
+
+	if t.Type != TransformRegexExtract {
+		return nil
+	}
+
+	re, err := regexp.Compile(t.Regex)
+	if err != nil {
+		return err
+	}
+
+	if t.Group < 0 || t.Group > re.NumSubexp() {
+		return fmt.Errorf("regex %q has no group %d", t.Regex, t.Group)
+	}
+
+	t.regex = re
+
+	return nil
+}
+
+// Apply returns the transformed value s.  A regex-extract step that does
+// not match returns a ParameterNodeError, so that the default value of the
+// argument applies.
+func (t *Transform) Apply(s string) (string, error) {
+	switch t.Type {
+	case TransformBase64Encode:
+		return base64.StdEncoding.EncodeToString([]byte(s)), nil
+
+	case TransformBase64Decode:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("error decoding base64 value: %w", err)
+		}
+
+		return string(b), nil
+
+	case TransformURLEncode:
+		return url.QueryEscape(s), nil
+
+	case TransformLower:
+		return strings.ToLower(s), nil
+
+	case TransformUpper:
+		return strings.ToUpper(s), nil
+
+	case TransformTrim:
+		return strings.TrimSpace(s), nil
+
+	case TransformRegexExtract:
+		re := t.regex
+		if re == nil {
+			if err := t.compile(); err != nil {
+				return "", err
+			}
+
+			re = t.regex
+		}
+
+		m := re.FindStringSubmatchIndex(s)
+		if m == nil || m[2*t.Group] < 0 {
+			return "", &ParameterNodeError{t.Regex}
+		}
+
+		return s[m[2*t.Group]:m[2*t.Group+1]], nil
+
+	case TransformJSONEscape:
+		var buf bytes.Buffer
+
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+
+		if err := enc.Encode(s); err != nil {
+			return "", err
+		}
+
+		// Strip the quotes and the trailing newline.
+		b := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+		return string(b[1 : len(b)-1]), nil
+
+	case TransformSHA256:
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:]), nil
+	}
+
+	return "", fmt.Errorf("unknown transform type %q", t.Type)
+}
@@ -0,0 +1,71 @@
+package hook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTransformApply(t *testing.T) {
+	for _, tt := range []struct {
+		desc      string
+		transform Transform
+		value     string
+		expected  string
+		ok        bool
+	}{
+		{"base64-encode", Transform{Type: TransformBase64Encode}, "hello", "aGVsbG8=", true},
+		{"base64-decode", Transform{Type: TransformBase64Decode}, "aGVsbG8=", "hello", true},
+		{"url-encode", Transform{Type: TransformURLEncode}, "a b&c=d", "a+b%26c%3Dd", true},
+		{"lower", Transform{Type: TransformLower}, "Main", "main", true},
+		{"upper", Transform{Type: TransformUpper}, "Main", "MAIN", true},
+		{"trim", Transform{Type: TransformTrim}, " main\n", "main", true},
+		{"regex-extract match", Transform{Type: TransformRegexExtract, Regex: "^refs/heads/(.+)$"}, "refs/heads/main", "refs/heads/main", true},
+		{"regex-extract group", Transform{Type: TransformRegexExtract, Regex: "^refs/heads/(.+)$", Group: 1}, "refs/heads/main", "main", true},
+		{"json-escape", Transform{Type: TransformJSONEscape}, "say \"hi\" <b>\n", `say \"hi\" <b>\n`, true},
+		{"sha256", Transform{Type: TransformSHA256}, "hello", "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", true},
+		// failures
+		{"invalid base64", Transform{Type: TransformBase64Decode}, "%%%", "", false},
+		{"regex-extract no match", Transform{Type: TransformRegexExtract, Regex: "^refs/tags/(.+)$", Group: 1}, "refs/heads/main", "", false},
+		{"unknown type", Transform{Type: "reverse"}, "main", "", false},
+	} {
+		value, err := tt.transform.Apply(tt.value)
+		if (err == nil) != tt.ok || value != tt.expected {
+			t.Errorf("%s failed:\nexpected {value:%q, ok:%v}\ngot {value:%q, err:%v}", tt.desc, tt.expected, tt.ok, value, err)
+		}
+	}
+}
+
+func TestArgumentGetTransform(t *testing.T) {
+	var args []Argument
+
+	err := json.Unmarshal([]byte(`[
+		{"source": "payload", "name": "ref", "transform": [{"type": "regex-extract", "regex": "^refs/heads/(.+)$", "group": 1}, "upper"]},
+		{"source": "payload", "name": "ref", "transform": [{"type": "regex-extract", "regex": "^refs/tags/(.+)$", "group": 1}], "default": "none"},
+		{"source": "payload", "name": "missing", "default": ""},
+		{"source": "payload", "name": "missing", "default": "fallback", "transform": ["upper"]},
+		{"source": "payload", "name": "ref", "transform": ["base64-decode"], "default": "fallback"},
+		{"source": "payload", "name": "missing"}
+	]`), &args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := &Request{Payload: map[string]interface{}{"ref": "refs/heads/main"}}
+
+	for i, tt := range []struct {
+		expected string
+		ok       bool
+	}{
+		{"MAIN", true},
+		{"none", true},
+		{"", true},
+		{"fallback", true},
+		{"", false},
+		{"", false},
+	} {
+		value, err := args[i].Get(req)
+		if (err == nil) != tt.ok || value != tt.expected {
+			t.Errorf("argument %d failed:\nexpected {value:%q, ok:%v}\ngot {value:%q, err:%v}", i, tt.expected, tt.ok, value, err)
+		}
+	}
+}
@@ -46,7 +46,7 @@ func (l *LogEntry) Write(status, totalBytes int, header http.Header, elapsed tim
 	}
 
 	fmt.Fprintf(l.buf, "%03d | %s | %s | ", status, humanize.IBytes(uint64(totalBytes)), elapsed)
-	l.buf.WriteString(l.req.Host + " | " + l.req.Method + " " + l.req.RequestURI)
+	l.buf.WriteString(l.req.RemoteAddr + " | " + l.req.Host + " | " + l.req.Method + " " + l.req.RequestURI)
 	log.Print(l.buf.String())
 }
 
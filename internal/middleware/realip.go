@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies parses a comma or space separated list of IP addresses
+// and CIDR ranges.
+func ParseTrustedProxies(s string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !strings.Contains(f, "/") {
+			ip := net.ParseIP(f)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", f)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, cidr, err := net.ParseCIDR(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy range %q", f)
+		}
+
+		nets = append(nets, cidr)
+	}
+
+	return nets, nil
+}
+
+// RealIP is a middleware that replaces the remote address of requests
+// received from trusted proxies with the client address reported by the
+// proxies in the X-Forwarded-For, X-Real-IP or Forwarded header, in that
+// order of preference.  The port of the new remote address is the one given
+// in the Forwarded header, or 0 if unknown.
+//
+// Requests from other addresses are not modified, so their forwarding headers
+// can not be used to spoof the client address.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if addr := ClientAddr(r, trusted); addr != r.RemoteAddr {
+				r2 := r.Clone(r.Context())
+				r2.RemoteAddr = addr
+				r = r2
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientAddr returns the address of the client that sent the request,
+// following the forwarding headers as long as the request was forwarded by
+// a trusted proxy.
+func ClientAddr(r *http.Request, trusted []*net.IPNet) string {
+	peer, _ := splitAddr(r.RemoteAddr)
+	if peer == nil || !containsIP(trusted, peer) {
+		return r.RemoteAddr
+	}
+
+	var hops []string
+
+	if v := r.Header.Values("X-Forwarded-For"); len(v) != 0 {
+		hops = splitList(v)
+	} else if v := r.Header.Get("X-Real-Ip"); v != "" {
+		hops = []string{strings.TrimSpace(v)}
+	} else if v := r.Header.Values("Forwarded"); len(v) != 0 {
+		hops = forwardedFor(v)
+	}
+
+	addr := r.RemoteAddr
+
+	// Walk the hops from the closest proxy, and stop at the first address
+	// that is not a trusted proxy.  An invalid address ends the walk, since
+	// the hops before it can not be trusted either.
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, port := splitAddr(hops[i])
+		if ip == nil {
+			break
+		}
+
+		if port == "" {
+			port = "0"
+		}
+
+		addr = net.JoinHostPort(ip.String(), port)
+
+		if !containsIP(trusted, ip) {
+			break
+		}
+	}
+
+	return addr
+}
+
+// containsIP reports whether ip is in any of the networks.
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitAddr parses an IP address with an optional port, such as "192.0.2.1",
+// "192.0.2.1:8080", "2001:db8::1" or "[2001:db8::1]:8080".
+func splitAddr(s string) (net.IP, string) {
+	if ip := net.ParseIP(strings.Trim(s, "[]")); ip != nil {
+		return ip, ""
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, ""
+	}
+
+	return net.ParseIP(host), port
+}
+
+// splitList splits comma separated header values into trimmed elements.
+func splitList(values []string) []string {
+	var l []string
+
+	for _, v := range values {
+		for _, e := range strings.Split(v, ",") {
+			l = append(l, strings.TrimSpace(e))
+		}
+	}
+
+	return l
+}
+
+// forwardedFor returns the "for" parameters of RFC 7239 Forwarded header
+// values.  Elements without one are returned as empty strings.
+func forwardedFor(values []string) []string {
+	var l []string
+
+	for _, element := range splitList(values) {
+		var node string
+
+		for _, pair := range strings.Split(element, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(k, "for") {
+				node = strings.Trim(v, `"`)
+			}
+		}
+
+		l = append(l, node)
+	}
+
+	return l
+}
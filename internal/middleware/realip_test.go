@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddr(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		desc       string
+		remoteAddr string
+		headers    map[string][]string
+		addr       string
+	}{
+		{"direct request", "203.0.113.7:1234", nil, "203.0.113.7:1234"},
+		{"untrusted peer", "203.0.113.7:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "203.0.113.7:1234"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "10.0.0.1:1234"},
+		{"x-forwarded-for", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}}, "198.51.100.1:0"},
+		{"x-forwarded-for chain", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.9, 198.51.100.1, 10.1.1.1"}}, "198.51.100.1:0"},
+		{"x-forwarded-for multiple headers", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"203.0.113.9", "198.51.100.1"}}, "198.51.100.1:0"},
+		{"x-forwarded-for all trusted", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"10.2.2.2, 192.0.2.1"}}, "10.2.2.2:0"},
+		{"x-forwarded-for invalid hop", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage, 10.1.1.1"}}, "10.1.1.1:0"},
+		{"x-forwarded-for with port", "192.0.2.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1:5555"}}, "198.51.100.1:5555"},
+		{"x-real-ip", "10.0.0.1:1234", map[string][]string{"X-Real-Ip": {"2001:db9::1"}}, "[2001:db9::1]:0"},
+		{"forwarded", "[2001:db8::1]:1234", map[string][]string{"Forwarded": {`for=198.51.100.1;proto=https, for="[2001:db8::2]:4711"`}}, "198.51.100.1:0"},
+		{"forwarded with port", "10.0.0.1:1234", map[string][]string{"Forwarded": {`For="[2001:db9::17]:4711"`}}, "[2001:db9::17]:4711"},
+		{"forwarded unknown", "10.0.0.1:1234", map[string][]string{"Forwarded": {`for=unknown`}}, "10.0.0.1:1234"},
+		{"x-forwarded-for preferred", "10.0.0.1:1234", map[string][]string{"X-Forwarded-For": {"198.51.100.1"}, "Forwarded": {"for=198.51.100.2"}}, "198.51.100.1:0"},
+	} {
+		r := httptest.NewRequest("POST", "/hooks/test", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header = http.Header(tt.headers)
+
+		if addr := ClientAddr(r, trusted); addr != tt.addr {
+			t.Errorf("%s failed:\nexpected %q\ngot %q", tt.desc, tt.addr, addr)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, s := range []string{"10.0.0.0/33", "proxy.local", "10.0.0.1,,bad"} {
+		if _, err := ParseTrustedProxies(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
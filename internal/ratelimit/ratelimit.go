@@ -0,0 +1,91 @@
+// Package ratelimit provides an in-memory set of token buckets keyed by an
+// arbitrary string, such as a client address or a payload value.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a set of token buckets sharing the same rate and burst size.
+// Buckets that have been idle long enough to refill completely are expired,
+// as they are indistinguishable from new ones.
+type Limiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens per second
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a Limiter that allows events at the given rate per second with
+// bursts of at most burst events.
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow reports whether an event for key may happen at the given time and
+// consumes a token if it may.  If the event is not allowed, Allow also returns
+// how long to wait until a token is available.
+func (l *Limiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(l.burst, b.tokens+elapsed*l.rate)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+
+	return false, wait
+}
+
+// Len returns the number of tracked keys.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// sweep removes buckets that have refilled completely.  It runs at most once
+// per refill period so that the cost is amortized across calls to Allow.
+func (l *Limiter) sweep(now time.Time) {
+	idle := time.Duration(l.burst / l.rate * float64(time.Second))
+
+	if now.Sub(l.lastSweep) < idle {
+		return
+	}
+
+	l.lastSweep = now
+
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= idle {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+
+	// 2 events per second, burst of 3.
+	l := New(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", start); !ok {
+			t.Fatalf("event %d should be allowed within the burst", i)
+		}
+	}
+
+	ok, wait := l.Allow("a", start)
+	if ok {
+		t.Fatal("event should be limited once the burst is used up")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("expected wait of 500ms, got %s", wait)
+	}
+
+	if ok, _ := l.Allow("b", start); !ok {
+		t.Error("keys should be limited independently")
+	}
+
+	if ok, _ := l.Allow("a", start.Add(500*time.Millisecond)); !ok {
+		t.Error("event should be allowed after a token is refilled")
+	}
+
+	if ok, _ := l.Allow("a", start.Add(500*time.Millisecond)); ok {
+		t.Error("event should be limited until the next token is refilled")
+	}
+}
+
+func TestLimiterExpiry(t *testing.T) {
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+
+	// 1 event per second, burst of 2; buckets refill completely after 2s.
+	l := New(1, 2)
+
+	l.Allow("a", start)
+	l.Allow("b", start.Add(1500*time.Millisecond))
+
+	if n := l.Len(); n != 2 {
+		t.Fatalf("expected 2 keys, got %d", n)
+	}
+
+	l.Allow("c", start.Add(2*time.Second))
+
+	if n := l.Len(); n != 2 {
+		t.Errorf("expected idle key to be expired, got %d keys", n)
+	}
+
+	if ok, _ := l.Allow("a", start.Add(2*time.Second)); !ok {
+		t.Error("expired key should start with a full bucket")
+	}
+}
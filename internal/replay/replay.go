@@ -0,0 +1,255 @@
+// Package replay provides a cache of recently seen delivery IDs, used to
+// reject requests that are replayed.
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// record is a line of the cache file.
+type record struct {
+	ID      string `json:"id"`
+	Expires int64  `json:"expires"`
+}
+
+// Cache is a set of IDs that expire after a fixed TTL.  If the cache has a
+// file, the IDs are persisted to it so that they survive restarts.  New IDs
+// are appended to the file, which is compacted when expired IDs are swept.
+type Cache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	path      string
+	file      *os.File
+	records   int
+	expires   map[string]time.Time
+	lastSweep time.Time
+}
+
+// New creates a Cache whose IDs expire after ttl.  If path is not empty, IDs
+// are loaded from and saved to that file, which is kept open until the
+// cache is closed.
+func New(ttl time.Duration, path string) (*Cache, error) {
+	c := &Cache{
+		ttl:     ttl,
+		path:    path,
+		expires: make(map[string]time.Time),
+	}
+
+	if path == "" {
+		return c, nil
+	}
+
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("error loading replay cache file %s: %w", path, err)
+	}
+
+	if err := c.compact(time.Now()); err != nil {
+		return nil, fmt.Errorf("error writing replay cache file %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// load reads the IDs from the cache file.  A partial last line, left by an
+// interrupted write, is ignored.
+func (c *Cache) load() error {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(nil, len(data)+1)
+
+	for n := 1; s.Scan(); n++ {
+		var r record
+
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil || r.ID == "" {
+			if n == bytes.Count(data, []byte("\n"))+1 {
+				break
+			}
+
+			return fmt.Errorf("invalid record on line %d", n)
+		}
+
+		if exp := time.Unix(r.Expires, 0); exp.After(c.expires[r.ID]) {
+			c.expires[r.ID] = exp
+		}
+	}
+
+	return s.Err()
+}
+
+// Seen reports whether id has been seen before and has not expired at the
+// given time.  If it has not, id is added to the cache.  An error is returned
+// if the cache file can not be written; id is still added in that case.
+func (c *Cache) Seen(id string, now time.Time) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var err error
+
+	if c.sweep(now) {
+		err = c.compact(now)
+	}
+
+	if exp, ok := c.expires[id]; ok && now.Before(exp) {
+		return true, err
+	}
+
+	c.expires[id] = now.Add(c.ttl)
+
+	if err == nil {
+		err = c.append(id)
+	}
+
+	return false, err
+}
+
+// Len returns the number of IDs in the cache, including expired ones which
+// have not been swept yet.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.expires)
+}
+
+// Close closes the cache file.  IDs are no longer saved after the cache is
+// closed.
+func (c *Cache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+
+	err := c.file.Close()
+	c.file = nil
+	c.path = ""
+
+	return err
+}
+
+// sweep removes expired IDs, at most once per TTL.  It reports whether the
+// cache file holds expired IDs which should be compacted.
+func (c *Cache) sweep(now time.Time) bool {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return false
+	}
+
+	c.lastSweep = now
+
+	for id, exp := range c.expires {
+		if !now.Before(exp) {
+			delete(c.expires, id)
+		}
+	}
+
+	return c.path != "" && c.records > len(c.expires)
+}
+
+// encode returns the record of id.
+func (c *Cache) encode(id string) ([]byte, error) {
+	// Round up, so that IDs never expire early after a reload.
+	data, err := json.Marshal(record{ID: id, Expires: c.expires[id].Add(time.Second - 1).Unix()})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+// append appends the record of id to the cache file.
+func (c *Cache) append(id string) error {
+	if c.file == nil {
+		return nil
+	}
+
+	data, err := c.encode(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.file.Write(data); err != nil {
+		return err
+	}
+
+	c.records++
+
+	return nil
+}
+
+// compact replaces the cache file atomically with the IDs which have not
+// expired at the given time, and reopens it for appending.
+func (c *Cache) compact(now time.Time) error {
+	if c.path == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	records := 0
+
+	for id, exp := range c.expires {
+		if !now.Before(exp) {
+			continue
+		}
+
+		data, err := c.encode(id)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(data)
+		records++
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	if err := os.Rename(f.Name(), c.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	file, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	if c.file != nil {
+		c.file.Close()
+	}
+
+	c.file = file
+	c.records = records
+
+	return nil
+}
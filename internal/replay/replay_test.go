@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheSeen(t *testing.T) {
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+
+	c, err := New(time.Hour, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seen, err := c.Seen("a", start); seen || err != nil {
+		t.Fatalf("first delivery should not be seen: seen: %v, err: %v", seen, err)
+	}
+
+	if seen, _ := c.Seen("a", start.Add(time.Minute)); !seen {
+		t.Error("duplicate delivery should be seen")
+	}
+
+	if seen, _ := c.Seen("b", start.Add(time.Minute)); seen {
+		t.Error("IDs should be tracked independently")
+	}
+
+	if seen, _ := c.Seen("a", start.Add(time.Hour)); seen {
+		t.Error("delivery should not be seen after the TTL")
+	}
+}
+
+func TestCacheSweep(t *testing.T) {
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+
+	c, _ := New(time.Minute, "")
+
+	for _, id := range []string{"a", "b", "c"} {
+		c.Seen(id, start)
+	}
+
+	c.Seen("d", start.Add(2*time.Minute))
+
+	if n := c.Len(); n != 1 {
+		t.Errorf("expected expired IDs to be swept, got %d IDs", n)
+	}
+}
+
+func TestCacheFile(t *testing.T) {
+	start := time.Date(2024, 3, 6, 10, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "replay.json")
+
+	c, err := New(time.Hour, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Seen("a", start); err != nil {
+		t.Fatalf("failed to save cache file: %s", err)
+	}
+
+	c, err = New(time.Hour, path)
+	if err != nil {
+		t.Fatalf("failed to load cache file: %s", err)
+	}
+
+	if seen, _ := c.Seen("a", start.Add(time.Minute)); !seen {
+		t.Error("delivery should be seen after reloading the cache file")
+	}
+
+	if seen, _ := c.Seen("a", start.Add(2*time.Hour)); seen {
+		t.Error("delivery should not be seen after the TTL")
+	}
+
+	if _, err := New(time.Hour, t.TempDir()); err == nil {
+		t.Error("expected error loading a directory as cache file")
+	}
+}
+
+func TestCacheFileAppend(t *testing.T) {
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "replay.json")
+
+	c, err := New(time.Minute, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	lines := func() int {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return bytes.Count(data, []byte("\n"))
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		if _, err := c.Seen(id, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c.Seen("a", now)
+
+	if n := lines(); n != 3 {
+		t.Errorf("expected one line per new ID, got %d lines", n)
+	}
+
+	// The next sweep compacts the file, dropping the expired IDs.
+	if _, err := c.Seen("d", now.Add(2*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := lines(); n != 1 {
+		t.Errorf("expected expired IDs to be compacted, got %d lines", n)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Seen("e", now.Add(2*time.Minute)); err != nil {
+		t.Errorf("closed cache should not write the file: %s", err)
+	}
+
+	if n := lines(); n != 1 {
+		t.Errorf("closed cache should not write the file, got %d lines", n)
+	}
+}
+
+func TestCacheFileInvalid(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	path := filepath.Join(t.TempDir(), "replay.json")
+
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A partial last line is left by an interrupted write.
+	write(fmt.Sprintf(`{"id": "a", "expires": %d}`+"\n"+`{"id": "b", "exp`, exp))
+
+	c, err := New(time.Hour, path)
+	if err != nil {
+		t.Fatalf("partial last line should be ignored: %s", err)
+	}
+	c.Close()
+
+	if c.Len() != 1 {
+		t.Errorf("expected 1 ID, got %d", c.Len())
+	}
+
+	write(fmt.Sprintf(`{"id": "a", "exp`+"\n"+`{"id": "b", "expires": %d}`+"\n", exp))
+
+	if _, err := New(time.Hour, path); err == nil {
+		t.Error("expected error loading a corrupt cache file")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/adnanh/webhook/internal/hook"
+	"github.com/adnanh/webhook/internal/ratelimit"
+)
+
+// hookLimiter is the rate limiter state for a single hook, along with the
+// configuration it was created from.
+type hookLimiter struct {
+	config  hook.RateLimit
+	limiter *ratelimit.Limiter
+}
+
+var (
+	hookLimitersMu sync.Mutex
+	hookLimiters   = make(map[string]*hookLimiter)
+)
+
+// getHookLimiter returns the rate limiter for the given hook.  Limiter state
+// is kept across hook reloads unless the hook's rate-limit configuration
+// changes.
+func getHookLimiter(h *hook.Hook) (*ratelimit.Limiter, error) {
+	hookLimitersMu.Lock()
+	defer hookLimitersMu.Unlock()
+
+	if hl, ok := hookLimiters[h.ID]; ok && reflect.DeepEqual(hl.config, *h.RateLimit) {
+		return hl.limiter, nil
+	}
+
+	rate, burst, err := h.RateLimit.Limits()
+	if err != nil {
+		return nil, err
+	}
+
+	hl := &hookLimiter{
+		config:  *h.RateLimit,
+		limiter: ratelimit.New(rate, burst),
+	}
+	hookLimiters[h.ID] = hl
+
+	return hl.limiter, nil
+}
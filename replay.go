@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/adnanh/webhook/internal/hook"
+	"github.com/adnanh/webhook/internal/replay"
+)
+
+// hookReplayCache is the replay protection state for a single hook, along
+// with the configuration it was created from.
+type hookReplayCache struct {
+	config hook.ReplayProtection
+	cache  *replay.Cache
+}
+
+var (
+	hookReplayCachesMu sync.Mutex
+	hookReplayCaches   = make(map[string]*hookReplayCache)
+)
+
+// newHookReplayCache creates the replay cache for the given hook.
+func newHookReplayCache(h *hook.Hook) (*hookReplayCache, error) {
+	ttl, err := h.ReplayProtection.Duration()
+	if err != nil {
+		return nil, err
+	}
+
+	cache, err := replay.New(ttl, h.ReplayProtection.File)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hookReplayCache{config: *h.ReplayProtection, cache: cache}, nil
+}
+
+// getHookReplayCache returns the replay cache for the given hook.  Caches are
+// opened when hooks are loaded; this only creates one if that failed.
+func getHookReplayCache(h *hook.Hook) (*replay.Cache, error) {
+	hookReplayCachesMu.Lock()
+	defer hookReplayCachesMu.Unlock()
+
+	if hc, ok := hookReplayCaches[h.ID]; ok && reflect.DeepEqual(hc.config, *h.ReplayProtection) {
+		return hc.cache, nil
+	}
+
+	hc, err := newHookReplayCache(h)
+	if err != nil {
+		return nil, err
+	}
+
+	if old, ok := hookReplayCaches[h.ID]; ok {
+		old.cache.Close()
+	}
+
+	hookReplayCaches[h.ID] = hc
+
+	return hc.cache, nil
+}
+
+// loadHookReplayCaches opens the replay caches of hooks about to be loaded
+// from hooksFilePath.  Seen IDs are kept across hook reloads unless the
+// hook's replay-protection configuration changes.  It fails if a cache file
+// can not be read or is shared with another hook.
+func loadHookReplayCaches(hooksFilePath string, hooks hook.Hooks) error {
+	files := make(map[string]string)
+
+	addFile := func(h *hook.Hook) error {
+		if h.ReplayProtection == nil || h.ReplayProtection.File == "" {
+			return nil
+		}
+
+		path, err := filepath.Abs(h.ReplayProtection.File)
+		if err != nil {
+			return err
+		}
+
+		if id, ok := files[path]; ok {
+			return fmt.Errorf("hooks %s and %s share the replay-protection file %s", id, h.ID, h.ReplayProtection.File)
+		}
+
+		files[path] = h.ID
+
+		return nil
+	}
+
+	for filePath, loaded := range loadedHooksFromFiles {
+		if filePath == hooksFilePath {
+			continue
+		}
+
+		for i := range loaded {
+			if err := addFile(&loaded[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := range hooks {
+		if err := addFile(&hooks[i]); err != nil {
+			return err
+		}
+	}
+
+	hookReplayCachesMu.Lock()
+	defer hookReplayCachesMu.Unlock()
+
+	opened := make(map[string]*hookReplayCache)
+
+	for i := range hooks {
+		h := &hooks[i]
+		if h.ReplayProtection == nil {
+			continue
+		}
+
+		if hc, ok := hookReplayCaches[h.ID]; ok && reflect.DeepEqual(hc.config, *h.ReplayProtection) {
+			continue
+		}
+
+		hc, err := newHookReplayCache(h)
+		if err != nil {
+			for _, hc := range opened {
+				hc.cache.Close()
+			}
+
+			return fmt.Errorf("hook %s: %w", h.ID, err)
+		}
+
+		opened[h.ID] = hc
+	}
+
+	for id, hc := range opened {
+		if old, ok := hookReplayCaches[id]; ok {
+			old.cache.Close()
+		}
+
+		hookReplayCaches[id] = hc
+	}
+
+	return nil
+}
+
+// pruneHookReplayCaches closes the replay caches of hooks which are no
+// longer loaded or no longer have replay protection.
+func pruneHookReplayCaches() {
+	hookReplayCachesMu.Lock()
+	defer hookReplayCachesMu.Unlock()
+
+	for id, hc := range hookReplayCaches {
+		if h := matchLoadedHook(id); h == nil || h.ReplayProtection == nil {
+			if err := hc.cache.Close(); err != nil {
+				log.Printf("error closing replay cache of hook %s: %s", id, err)
+			}
+
+			delete(hookReplayCaches, id)
+		}
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"io"
 	"log"
+	"os"
 	"strings"
 )
 
@@ -83,3 +85,49 @@ func getTLSCipherSuites(v string) []uint16 {
 
 	return suites
 }
+
+// getTLSClientAuth converts a client authentication mode into a TLS client
+// authentication policy, loading the CA certificates used to verify client
+// certificates from caFile.  If no mode is given, client certificates are
+// verified if a CA file is given and not requested otherwise.
+func getTLSClientAuth(mode, caFile string) (tls.ClientAuthType, *x509.CertPool) {
+	var pool *x509.CertPool
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalln("error: unable to read TLS client CA file:", err)
+		}
+
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			log.Fatalln("error: no certificates found in TLS client CA file:", caFile)
+		}
+	}
+
+	switch mode {
+	case "":
+		if pool != nil {
+			return tls.RequireAndVerifyClientCert, pool
+		}
+
+		return tls.NoClientCert, nil
+	case "request":
+		if pool == nil {
+			log.Fatalln("error: TLS client authentication mode request requires -tls-client-ca")
+		}
+
+		return tls.VerifyClientCertIfGiven, pool
+	case "require":
+		return tls.RequireAnyClientCert, pool
+	case "verify":
+		if pool == nil {
+			log.Fatalln("error: TLS client authentication mode verify requires -tls-client-ca")
+		}
+
+		return tls.RequireAndVerifyClientCert, pool
+	default:
+		log.Fatalln("error: unknown TLS client authentication mode:", mode)
+		return tls.NoClientCert, nil
+	}
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,6 +31,10 @@ import (
 
 const (
 	version = "2.8.2"
+
+	// defaultMaxDecompressedBodySize limits decompressed request bodies of
+	// hooks without a body size limit.
+	defaultMaxDecompressedBodySize = 10 << 20
 )
 
 var (
@@ -45,11 +54,15 @@ var (
 	justListCiphers    = flag.Bool("list-cipher-suites", false, "list available TLS cipher suites")
 	tlsMinVersion      = flag.String("tls-min-version", "1.2", "minimum TLS version (1.0, 1.1, 1.2, 1.3)")
 	tlsCipherSuites    = flag.String("cipher-suites", "", "comma-separated list of supported TLS cipher suites")
+	tlsClientCA        = flag.String("tls-client-ca", "", "path to a PEM file with the CA certificates used to verify TLS client certificates")
+	tlsClientAuth      = flag.String("tls-client-auth", "", "TLS client certificate mode: request (verify if given), require (any certificate) or verify (require and verify); defaults to verify if -tls-client-ca is set")
 	useXRequestID      = flag.Bool("x-request-id", false, "use X-Request-Id header, if present, as request ID")
 	xRequestIDLimit    = flag.Int("x-request-id-limit", 0, "truncate X-Request-Id header to limit; default no limit")
 	maxMultipartMem    = flag.Int64("max-multipart-mem", 1<<20, "maximum memory in bytes for parsing multipart form data before disk caching")
+	maxBodySize        = flag.Int64("max-body-size", 0, "maximum size in bytes of request bodies, which hooks can override with max-body-size; 0 means no limit")
 	httpMethods        = flag.String("http-methods", "", `set default allowed HTTP methods (ie. "POST"); separate methods with comma`)
 	pidPath            = flag.String("pidfile", "", "create PID file at the given path")
+	trustedProxies     = flag.String("trusted-proxies", "", "comma-separated list of IP addresses and CIDR ranges of reverse proxies trusted to report the client address in the X-Forwarded-For, X-Real-IP or Forwarded header")
 
 	responseHeaders hook.ResponseHeaders
 	hooksFiles      hook.HooksFiles
@@ -75,6 +88,48 @@ func matchLoadedHook(id string) *hook.Hook {
 	return nil
 }
 
+// matchLoadedHookPath returns the hook served at the given path and the
+// parameters captured from the path.  Hooks without a path are matched by ID
+// across all files first, then path patterns in the order the hooks files
+// were given.
+func matchLoadedHookPath(path string) (*hook.Hook, map[string]interface{}) {
+	for _, hooksFilePath := range hooksFiles {
+		hooks := loadedHooksFromFiles[hooksFilePath]
+		if hook := hooks.MatchPathID(path); hook != nil {
+			return hook, nil
+		}
+	}
+
+	for _, hooksFilePath := range hooksFiles {
+		hooks := loadedHooksFromFiles[hooksFilePath]
+		if hook, params := hooks.MatchPathPattern(path); hook != nil {
+			return hook, params
+		}
+	}
+
+	return nil, nil
+}
+
+// checkLoadedHookPaths checks that the path patterns of hooks about to be
+// loaded from hooksFilePath do not overlap those loaded from other files.
+func checkLoadedHookPaths(hooksFilePath string, hooks hook.Hooks) error {
+	for filePath, loaded := range loadedHooksFromFiles {
+		if filePath == hooksFilePath {
+			continue
+		}
+
+		for i := range hooks {
+			for j := range loaded {
+				if hooks[i].OverlapsPath(&loaded[j]) {
+					return fmt.Errorf("the path %q of hook %s overlaps the path %q of hook %s", hooks[i].Path, hooks[i].ID, loaded[j].Path, loaded[j].ID)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 func lenLoadedHooks() int {
 	sum := 0
 	for _, hooks := range loadedHooksFromFiles {
@@ -107,6 +162,17 @@ func main() {
 		os.Exit(0)
 	}
 
+	if !*secure && (*tlsClientCA != "" || *tlsClientAuth != "") {
+		fmt.Println("error: tls-client-ca and tls-client-auth options require secure")
+		os.Exit(1)
+	}
+
+	proxies, err := middleware.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+
 	if (setUID != 0 || setGID != 0) && (setUID == 0 || setGID == 0) {
 		fmt.Println("error: setuid and setgid options must be used together")
 		os.Exit(1)
@@ -218,6 +284,15 @@ func main() {
 				log.Printf("\tloaded: %s\n", hook.ID)
 			}
 
+			if err := checkLoadedHookPaths(hooksFilePath, newHooks); err != nil {
+				log.Fatalf("error: %s!\nplease check your hooks files for overlapping hook paths!\n", err)
+			}
+
+			if err := loadHookReplayCaches(hooksFilePath, newHooks); err != nil {
+				log.Printf("couldn't load hooks from file! %+v\n", err)
+				continue
+			}
+
 			loadedHooksFromFiles[hooksFilePath] = newHooks
 		}
 	}
@@ -265,6 +340,9 @@ func main() {
 		middleware.UseXRequestIDHeaderOption(*useXRequestID),
 		middleware.XRequestIDLimitOption(*xRequestIDLimit),
 	))
+	if len(proxies) != 0 {
+		r.Use(middleware.RealIP(proxies))
+	}
 	r.Use(middleware.NewLogger())
 	r.Use(chimiddleware.Recoverer)
 
@@ -301,11 +379,15 @@ func main() {
 	}
 
 	// Server HTTPS
+	clientAuth, clientCAs := getTLSClientAuth(*tlsClientAuth, *tlsClientCA)
+
 	svr.TLSConfig = &tls.Config{
 		CipherSuites:             getTLSCipherSuites(*tlsCipherSuites),
 		CurvePreferences:         []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
 		MinVersion:               getTLSMinVersion(*tlsMinVersion),
 		PreferServerCipherSuites: true,
+		ClientAuth:               clientAuth,
+		ClientCAs:                clientCAs,
 	}
 	svr.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler)) // disable http/2
 
@@ -324,22 +406,26 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 	// TODO: rename this to avoid confusion with Request.ID
 	id := mux.Vars(r)["id"]
 
-	matchedHook := matchLoadedHook(id)
+	matchedHook, pathParams := matchLoadedHookPath(id)
 	if matchedHook == nil {
 		w.WriteHeader(http.StatusNotFound)
 		fmt.Fprint(w, "Hook not found.")
 		return
 	}
 
+	// Hooks with a path pattern are served at paths other than their ID.
+	id = matchedHook.ID
+	req.HookID = matchedHook.ID
+	req.PathParameters = pathParams
+
 	// Check for allowed methods
 	var allowedMethod bool
 
 	switch {
 	case len(matchedHook.HTTPMethods) != 0:
 		for i := range matchedHook.HTTPMethods {
-			// TODO(moorereason): refactor config loading and reloading to
-			// sanitize these methods once at load time.
-			if r.Method == strings.ToUpper(strings.TrimSpace(matchedHook.HTTPMethods[i])) {
+			// Methods are normalized when the hooks are loaded.
+			if r.Method == matchedHook.HTTPMethods[i] {
 				allowedMethod = true
 				break
 			}
@@ -378,17 +464,67 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 
 	isMultipart := strings.HasPrefix(req.ContentType, "multipart/form-data;")
 
+	if limit := bodySizeLimit(matchedHook); limit > 0 {
+		if r.ContentLength > limit {
+			writeBodyTooLarge(w, req.ID, &http.MaxBytesError{Limit: limit})
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	// Decompress the body before parsing it.  The compressed body is kept
+	// for signature rules verifying it.
+	var compressed *bytes.Buffer
+
+	encoding := r.Header.Get("Content-Encoding")
+	decompress := matchedHook.DecompressBody && encoding != ""
+
+	if decompress {
+		var body io.Reader = r.Body
+		if !isMultipart {
+			compressed = new(bytes.Buffer)
+			body = io.TeeReader(r.Body, compressed)
+		}
+
+		rc, err := hook.NewDecompressReader(body, encoding)
+		if err != nil {
+			writeDecompressError(w, req.ID, err)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, rc, decompressedBodySizeLimit(matchedHook))
+	}
+
 	if !isMultipart {
 		req.Body, err = ioutil.ReadAll(r.Body)
+		if decompress && err != nil {
+			writeDecompressError(w, req.ID, err)
+			return
+		}
+		if isMaxBytesError(err) {
+			writeBodyTooLarge(w, req.ID, err)
+			return
+		}
 		if err != nil {
 			log.Printf("[%s] error reading the request body: %+v\n", req.ID, err)
 		}
+
+		if compressed != nil {
+			req.CompressedBody = compressed.Bytes()
+		}
 	}
 
 	req.ParseHeaders(r.Header)
 	req.ParseQuery(r.URL.Query())
 
 	switch {
+	case strings.Contains(req.ContentType, "ndjson"), strings.Contains(req.ContentType, "jsonl"):
+		err = req.ParseNDJSONPayload()
+		if err != nil {
+			log.Printf("[%s] %s", req.ID, err)
+		}
+
 	case strings.Contains(req.ContentType, "json"):
 		err = req.ParseJSONPayload()
 		if err != nil {
@@ -407,8 +543,30 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 			log.Printf("[%s] %s", req.ID, err)
 		}
 
+	case strings.Contains(req.ContentType, "yaml"):
+		err = req.ParseYAMLPayload()
+		if err != nil {
+			log.Printf("[%s] %s", req.ID, err)
+		}
+
+	case strings.Contains(req.ContentType, "msgpack"):
+		err = req.ParseMsgPackPayload()
+		if err != nil {
+			log.Printf("[%s] %s", req.ID, err)
+		}
+
+	case strings.Contains(req.ContentType, "cbor"):
+		err = req.ParseCBORPayload()
+		if err != nil {
+			log.Printf("[%s] %s", req.ID, err)
+		}
+
 	case isMultipart:
 		err = r.ParseMultipartForm(*maxMultipartMem)
+		if isMaxBytesError(err) {
+			writeBodyTooLarge(w, req.ID, err)
+			return
+		}
 		if err != nil {
 			msg := fmt.Sprintf("[%s] error parsing multipart form: %+v\n", req.ID, err)
 			log.Println(msg)
@@ -417,17 +575,12 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		for k, v := range r.MultipartForm.Value {
+		for k := range r.MultipartForm.Value {
 			log.Printf("[%s] found multipart form value %q", req.ID, k)
-
-			if req.Payload == nil {
-				req.Payload = make(map[string]interface{})
-			}
-
-			// TODO(moorereason): support duplicate, named values
-			req.Payload[k] = v[0]
 		}
 
+		req.ParseFormValues(r.MultipartForm.Value)
+
 		for k, v := range r.MultipartForm.File {
 			// Force parsing as JSON regardless of Content-Type.
 			var parseAsJSON bool
@@ -498,8 +651,13 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 	} else {
 		// Save signature soft failures option in request for evaluators
 		req.AllowSignatureErrors = matchedHook.TriggerSignatureSoftFailures
+		req.TraceRules = *debug || matchedHook.ExplainMismatch
 
 		ok, err = matchedHook.TriggerRule.Evaluate(req)
+
+		if *debug {
+			log.Printf("[%s] rule evaluation trace:\n%s\n", req.ID, req.RuleTrace)
+		}
 		if err != nil {
 			if !hook.IsParameterNodeError(err) {
 				msg := fmt.Sprintf("[%s] error evaluating hook: %s", req.ID, err)
@@ -513,6 +671,59 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if ok && matchedHook.RateLimit != nil {
+		limiter, err := getHookLimiter(matchedHook)
+		if err != nil {
+			log.Printf("[%s] error creating rate limiter: %s", req.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "Error occurred while checking the hook's rate limit.")
+			return
+		}
+
+		key, err := matchedHook.RateLimit.BucketKey(req)
+		if err != nil {
+			log.Printf("[%s] error extracting rate limit key, using the shared bucket: %s", req.ID, err)
+		}
+
+		if allowed, wait := limiter.Allow(key, time.Now()); !allowed {
+			log.Printf("[%s] %s got matched, but didn't get triggered because the rate limit was exceeded\n", req.ID, matchedHook.ID)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, "Rate limit exceeded.")
+			return
+		}
+	}
+
+	if ok && matchedHook.ReplayProtection != nil {
+		cache, err := getHookReplayCache(matchedHook)
+		if err != nil {
+			log.Printf("[%s] error creating replay cache: %s", req.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "Error occurred while checking the hook's replay protection.")
+			return
+		}
+
+		id, err := matchedHook.ReplayProtection.DeliveryID(req)
+		if err != nil {
+			log.Printf("[%s] %s got matched, but didn't get triggered because the delivery ID could not be extracted: %s\n", req.ID, matchedHook.ID, err)
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, "Missing delivery ID.")
+			return
+		}
+
+		seen, err := cache.Seen(id, time.Now())
+		if err != nil {
+			log.Printf("[%s] error saving replay cache: %s", req.ID, err)
+		}
+
+		if seen {
+			log.Printf("[%s] %s got matched, but didn't get triggered because delivery %s was already received\n", req.ID, matchedHook.ID, id)
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, "Duplicate delivery.")
+			return
+		}
+	}
+
 	if ok {
 		log.Printf("[%s] %s hook triggered successfully\n", req.ID, matchedHook.ID)
 
@@ -539,20 +750,39 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 				fmt.Fprint(w, response)
 			}
 		} else {
+			msg, err := matchedHook.RenderResponseMessage(req)
+			if err != nil {
+				log.Printf("[%s] error rendering response message: %s", req.ID, err)
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, "Error occurred while rendering the response message.")
+				return
+			}
+
 			go handleHook(matchedHook, req)
 
+			// The message may contain request values, so it must not be
+			// sniffed as HTML.
+			if w.Header().Get("Content-Type") == "" {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			}
+
 			// Check if a success return code is configured for the hook
 			if matchedHook.SuccessHttpResponseCode != 0 {
 				writeHttpResponseCode(w, req.ID, matchedHook.ID, matchedHook.SuccessHttpResponseCode)
 			}
 
-			fmt.Fprint(w, matchedHook.ResponseMessage)
+			fmt.Fprint(w, msg)
 		}
 		return
 	}
 
-	// Check if a return code is configured for the hook
-	if matchedHook.TriggerRuleMismatchHttpResponseCode != 0 {
+	// Ask for credentials if an authentication rule failed, otherwise check if
+	// a return code is configured for the hook
+	if req.AuthChallenge != "" {
+		w.Header().Set("WWW-Authenticate", req.AuthChallenge)
+		w.WriteHeader(http.StatusUnauthorized)
+	} else if matchedHook.TriggerRuleMismatchHttpResponseCode != 0 {
 		writeHttpResponseCode(w, req.ID, matchedHook.ID, matchedHook.TriggerRuleMismatchHttpResponseCode)
 	}
 
@@ -560,6 +790,10 @@ func hookHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[%s] %s got matched, but didn't get triggered because the trigger rules were not satisfied\n", req.ID, matchedHook.ID)
 
 	fmt.Fprint(w, "Hook rules were not satisfied.")
+
+	if matchedHook.ExplainMismatch && req.RuleTrace != nil {
+		fmt.Fprintf(w, "\n\n%s\n", req.RuleTrace)
+	}
 }
 
 func handleHook(h *hook.Hook, r *hook.Request) (string, error) {
@@ -699,7 +933,20 @@ func reloadHooks(hooksFilePath string) {
 			log.Printf("\tloaded: %s\n", hook.ID)
 		}
 
+		if err := checkLoadedHookPaths(hooksFilePath, hooksInFile); err != nil {
+			log.Printf("error: %s!\nplease check your hooks files for overlapping hook paths!", err)
+			log.Println("reverting hooks back to the previous configuration")
+			return
+		}
+
+		if err := loadHookReplayCaches(hooksFilePath, hooksInFile); err != nil {
+			log.Printf("error: %s", err)
+			log.Println("reverting hooks back to the previous configuration")
+			return
+		}
+
 		loadedHooksFromFiles[hooksFilePath] = hooksInFile
+		pruneHookReplayCaches()
 	}
 }
 
@@ -726,6 +973,7 @@ func removeHooks(hooksFilePath string) {
 	removedHooksCount := len(loadedHooksFromFiles[hooksFilePath])
 
 	delete(loadedHooksFromFiles, hooksFilePath)
+	pruneHookReplayCaches()
 
 	log.Printf("removed %d hook(s) that were loaded from file %s\n", removedHooksCount, hooksFilePath)
 
@@ -769,6 +1017,70 @@ func watchForFileChange() {
 	}
 }
 
+// bodySizeLimit returns the maximum request body size for the hook: its
+// max-body-size, or the -max-body-size flag.  Zero means no limit.
+func bodySizeLimit(h *hook.Hook) int64 {
+	if h.MaxBodySize > 0 {
+		return h.MaxBodySize
+	}
+
+	return *maxBodySize
+}
+
+// decompressedBodySizeLimit returns the maximum size of the decompressed
+// request body for the hook: its max-decompressed-body-size, or else the
+// request body size limit, or else defaultMaxDecompressedBodySize.
+func decompressedBodySizeLimit(h *hook.Hook) int64 {
+	if h.MaxDecompressedBodySize > 0 {
+		return h.MaxDecompressedBodySize
+	}
+
+	if limit := bodySizeLimit(h); limit > 0 {
+		return limit
+	}
+
+	return defaultMaxDecompressedBodySize
+}
+
+// isMaxBytesError reports whether err was caused by reading more than the
+// limit of an http.MaxBytesReader.
+func isMaxBytesError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// writeBodyTooLarge rejects a request whose body exceeds the size limit.
+func writeBodyTooLarge(w http.ResponseWriter, rid string, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		log.Printf("[%s] request body exceeds the limit of %d bytes\n", rid, maxBytesErr.Limit)
+	}
+
+	w.Header().Set("Connection", "close")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	fmt.Fprint(w, "Request body too large.")
+}
+
+// writeDecompressError rejects a request whose body can not be decompressed.
+func writeDecompressError(w http.ResponseWriter, rid string, err error) {
+	var encodingErr *hook.UnsupportedEncodingError
+
+	switch {
+	case isMaxBytesError(err):
+		writeBodyTooLarge(w, rid, err)
+
+	case errors.As(err, &encodingErr):
+		log.Printf("[%s] %v\n", rid, err)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		fmt.Fprint(w, "Unsupported content encoding.")
+
+	default:
+		log.Printf("[%s] error decompressing the request body: %v\n", rid, err)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, "Error occurred while decompressing the request body.")
+	}
+}
+
 // valuesToMap converts map[string][]string to a map[string]string object
 func valuesToMap(values map[string][]string) map[string]interface{} {
 	ret := make(map[string]interface{})